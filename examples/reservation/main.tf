@@ -54,3 +54,21 @@ resource "turbonomic_reservation" "reservation" {
   }
 }
 */
+
+// A single reservation for a cluster of VMs.  Each VM uses the placement
+// generated for it.
+/*
+resource "turbonomic_reservation" "cluster" {
+  action                = "RESERVATION"
+  constraint_ids        = ["${data.turbonomic_market_policy.policy.id}"]
+  deployment_profile_id = "${data.turbonomic_deployment_profile.profile.id}"
+  entity_names          = ["${formatlist("%s%d", var.vsphere_vm_name, list(1, 2, 3))}"]
+  template_id           = "${turbonomic_template.template.id}"
+}
+
+data "vsphere_host" "host" {
+  count         = 3
+  name          = "${lookup(turbonomic_reservation.cluster.placement[count.index], "compute_provider")}"
+  datacenter_id = "${data.vsphere_datacenter.dc.id}"
+}
+*/
@@ -12,4 +12,8 @@ func main() {
 	}
 	// Serves the Turbonomic plugin in the defined configurations.
 	plugin.Serve(&opts)
+	// Terraform is done with the plugin: report any API requests made since
+	// the last operation.  This is skipped when Terraform kills the process,
+	// hence the summaries logged after every operation.
+	turbonomic.LogRequestSummary()
 }
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+const (
+	// LoginPrefix - API endpoint used to establish a session with Turbonomic
+	LoginPrefix = "login"
+	// DefaultTokenPath - path of the OAuth2 token endpoint on the Turbonomic
+	// server.  Used when the credentials do not provide a TokenURL.
+	DefaultTokenPath = "/oauth2/token"
+	// tokenExpiryDelta - access tokens are refreshed this long before they
+	// expire so that a token never expires while a request is in flight.
+	tokenExpiryDelta = 30 * time.Second
+)
+
+// -----------------------------------------------------------------------------
+// Request Authorization
+// -----------------------------------------------------------------------------
+
+// authorize prepares the request according to the client's authentication
+// method, logging in or obtaining an access token first if needed.  It returns
+// the authentication generation the request was sent with, which is handed
+// back to reauthenticate if the server rejects the request.
+func (client *Client) authorize(req *http.Request) (uint64, error) {
+	client.authMutex.Lock()
+	defer client.authMutex.Unlock()
+
+	switch client.credentials.AuthMethod {
+	case AuthMethodSession:
+		if !client.sessionActive {
+			if loginErr := client.login(req.Context()); loginErr != nil {
+				return 0, loginErr
+			}
+		}
+	case AuthMethodBasic:
+		req.SetBasicAuth(client.credentials.Username, client.credentials.Password)
+	case AuthMethodOAuth2:
+		if client.accessToken == "" ||
+			time.Now().Add(tokenExpiryDelta).After(client.accessTokenExpiry) {
+			if tokenErr := client.fetchToken(req.Context()); tokenErr != nil {
+				return 0, tokenErr
+			}
+		}
+		req.Header.Set("Authorization", "Bearer "+client.accessToken)
+	case AuthMethodToken:
+		req.Header.Set("Authorization", "Bearer "+client.credentials.Token)
+	default:
+		return 0, fmt.Errorf(
+			"Unsupported authentication method: [%s]",
+			client.credentials.AuthMethod,
+		)
+	}
+	return client.authGeneration, nil
+}
+
+// canReauthenticate reports whether a rejected request can be fixed by
+// logging in again or obtaining a new token.  Static credentials cannot.
+func (client *Client) canReauthenticate() bool {
+	return client.credentials.AuthMethod == AuthMethodSession ||
+		client.credentials.AuthMethod == AuthMethodOAuth2
+}
+
+// reauthenticate discards the session or access token the request was sent
+// with and authorizes the request again.  If another request already
+// re-authenticated since generation, the new session or token is reused.
+func (client *Client) reauthenticate(req *http.Request, generation uint64) error {
+	client.authMutex.Lock()
+	if client.authGeneration == generation {
+		client.sessionActive = false
+		client.accessToken = ""
+	} else {
+		log.Debugf("Turbonomic credentials already renewed by another request")
+	}
+	client.authMutex.Unlock()
+
+	_, authErr := client.authorize(req)
+	return authErr
+}
+
+// -----------------------------------------------------------------------------
+// Session Management
+// -----------------------------------------------------------------------------
+
+// Login - Authenticates the client against Turbonomic using the configured
+// credentials.  On success, the server's session cookie is stored in the
+// client's cookie jar and sent automatically on subsequent requests.
+//
+// Login is only meaningful for AuthMethodSession.  Clients configured for
+// other authentication methods return immediately.
+func (client *Client) Login(ctx context.Context) error {
+	log.Tracef("Turbonomic.auth.go#Login")
+
+	if client.credentials.AuthMethod != AuthMethodSession {
+		return nil
+	}
+
+	client.authMutex.Lock()
+	defer client.authMutex.Unlock()
+	return client.login(ctx)
+}
+
+// login posts the credentials to the login endpoint.  The caller must hold
+// authMutex.
+func (client *Client) login(ctx context.Context) error {
+	client.sessionActive = false
+
+	loginURL := client.Server
+	loginURL.Path = client.options.APIBasePath + "/" + LoginPrefix
+	loginURL.RawQuery = ""
+
+	form := url.Values{}
+	form.Set("username", client.credentials.Username)
+	form.Set("password", client.credentials.Password)
+
+	resp, respErr := client.postForm(ctx, loginURL.String(), form)
+	if respErr != nil {
+		log.Errorf(
+			"Error encountered when logging in to Turbonomic\n"+
+				"  Error: %s",
+			respErr.Error(),
+		)
+		return respErr
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return authFailure(
+			resp,
+			respBody,
+			fmt.Sprintf("Failed to log in to Turbonomic as [%s]", client.credentials.Username),
+		)
+	}
+	// The body (the logged in user) is not needed.  Drain it so the
+	// connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+
+	client.sessionActive = true
+	client.authGeneration++
+	log.Debugf("Logged in to Turbonomic as [%s]", client.credentials.Username)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// OAuth2 Token Management
+// -----------------------------------------------------------------------------
+
+// tokenResponse is the successful response of an OAuth2 token endpoint
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type,omitempty"`
+	// Lifetime of the access token in seconds
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+}
+
+// fetchToken requests a new access token with the OAuth2 client credentials
+// grant and caches it on the client.  The caller must hold authMutex.
+func (client *Client) fetchToken(ctx context.Context) error {
+	client.accessToken = ""
+
+	tokenURL := client.credentials.TokenURL
+	if tokenURL == "" {
+		defaultURL := client.Server
+		defaultURL.Path = DefaultTokenPath
+		defaultURL.RawQuery = ""
+		tokenURL = defaultURL.String()
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", client.credentials.ClientID)
+	form.Set("client_secret", client.credentials.ClientSecret)
+
+	resp, respErr := client.postForm(ctx, tokenURL, form)
+	if respErr != nil {
+		log.Errorf(
+			"Error encountered when requesting an OAuth2 access token\n"+
+				"  Error: %s",
+			respErr.Error(),
+		)
+		return respErr
+	}
+	defer resp.Body.Close()
+
+	respBody, readErr := ioutil.ReadAll(resp.Body)
+	if readErr != nil {
+		return readErr
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return authFailure(
+			resp,
+			respBody,
+			fmt.Sprintf("Failed to obtain an OAuth2 access token for client [%s]", client.credentials.ClientID),
+		)
+	}
+
+	var token tokenResponse
+	if jsonDecErr := json.Unmarshal(respBody, &token); jsonDecErr != nil {
+		return jsonDecErr
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf(
+			"Token endpoint [%s] did not return an access token",
+			tokenURL,
+		)
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return fmt.Errorf(
+			"Token endpoint [%s] returned unsupported token type [%s]",
+			tokenURL,
+			token.TokenType,
+		)
+	}
+
+	client.accessToken = token.AccessToken
+	if token.ExpiresIn > 0 {
+		client.accessTokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	} else {
+		// No lifetime reported: keep the token until the server rejects it
+		client.accessTokenExpiry = time.Now().Add(24 * time.Hour)
+	}
+	client.authGeneration++
+	log.Debugf(
+		"Obtained OAuth2 access token for client [%s], expires at [%s]",
+		client.credentials.ClientID,
+		client.accessTokenExpiry.Format(time.RFC3339),
+	)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Helpers
+// -----------------------------------------------------------------------------
+
+// postForm sends a form-encoded POST request directly through the underlying
+// HTTP client, bypassing the authorization performed by Send.  The caller is
+// responsible for closing the response body.
+func (client *Client) postForm(ctx context.Context, target string, form url.Values) (*http.Response, error) {
+	req, reqErr := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		target,
+		strings.NewReader(form.Encode()),
+	)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	req.Header.Set("User-Agent", "terraform-provider-turbonomic")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return client.httpClient.Do(req)
+}
+
+// authFailure returns the APIError of a rejected login or token request.  The
+// message describing the failed authentication is prepended to the message
+// returned by the server, if any.
+func authFailure(resp *http.Response, respBody []byte, message string) *APIError {
+	apiErr := newAPIError(resp.Request, resp.StatusCode, respBody)
+	if apiErr.Response.Message != "" {
+		message = fmt.Sprintf("%s: %s", message, apiErr.Response.Message)
+	}
+	apiErr.Response.Message = message
+	return apiErr
+}
+
+// rewindRequestBody resets the body of a request that has already been sent
+// so that it can be sent again.  Requests without a body need no rewinding.
+//
+// NOTE(ALL): net/http only populates GetBody for *bytes.Buffer,
+//   *bytes.Reader and *strings.Reader bodies.  All helper functions in this
+//   package use one of these types.
+func rewindRequestBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		return fmt.Errorf(
+			"Cannot replay request [%s %s]: the request body cannot be rewound",
+			req.Method,
+			req.URL,
+		)
+	}
+	body, bodyErr := req.GetBody()
+	if bodyErr != nil {
+		return bodyErr
+	}
+	req.Body = body
+	return nil
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// cachedResponse is a response read from the server, shared by every caller
+// served from the cache.  Callers must treat the body as read-only.
+type cachedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	err        error
+	// Time after which the response is no longer served from the cache
+	expires time.Time
+}
+
+// inFlightRequest is a GET request currently being sent on behalf of every
+// caller that asked for the same URL while it was in flight.
+type inFlightRequest struct {
+	done     chan struct{}
+	response cachedResponse
+	// Whether the caller sending the request gave up on it, in which case
+	// its response is not shared with the other callers
+	abandoned bool
+}
+
+// responseCache is an in-memory cache of successful GET responses, keyed by
+// URL.  Concurrent identical GETs are coalesced into a single request.
+// Mutating requests invalidate the cached responses of the collection they
+// modify (ie: a POST to /templates invalidates every /templates URL).
+type responseCache struct {
+	mutex sync.Mutex
+	// How long a response is served from the cache
+	ttl       time.Duration
+	responses map[string]cachedResponse
+	inFlight  map[string]*inFlightRequest
+	// Incremented on every invalidation.  Responses of requests that started
+	// before an invalidation are not cached, since they may be stale.
+	generation uint64
+}
+
+// newResponseCache returns an empty cache serving responses for ttl.
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:       ttl,
+		responses: map[string]cachedResponse{},
+		inFlight:  map[string]*inFlightRequest{},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Cache Operations
+// -----------------------------------------------------------------------------
+
+// get returns the response for the GET request to url, calling send only if
+// the response is neither cached nor already being fetched by another
+// caller.  Only 2xx responses are cached.  ctx is the context of the caller:
+// a caller waiting for an in-flight request stops waiting when it is done.
+//
+// NOTE(ALL): the outcome of an in-flight request is only shared if the
+//   caller that sent it did not give up on it.  Otherwise every waiter
+//   would fail with that caller's cancellation, so the waiters fetch the
+//   URL again instead, one of them sending the request for the others.
+func (cache *responseCache) get(ctx context.Context, url string, send func() cachedResponse) cachedResponse {
+	for {
+		cache.mutex.Lock()
+		if cached, ok := cache.responses[url]; ok {
+			if time.Now().Before(cached.expires) {
+				cache.mutex.Unlock()
+				log.Debugf("Serving [GET %s] from the response cache", url)
+				return cached
+			}
+			delete(cache.responses, url)
+		}
+		if flight, ok := cache.inFlight[url]; ok {
+			cache.mutex.Unlock()
+			log.Debugf("Waiting for in-flight [GET %s]", url)
+			select {
+			case <-ctx.Done():
+				return cachedResponse{statusCode: -1, err: ctx.Err()}
+			case <-flight.done:
+			}
+			if !flight.abandoned {
+				return flight.response
+			}
+			log.Debugf("In-flight [GET %s] was abandoned, fetching it again", url)
+			continue
+		}
+		flight := &inFlightRequest{done: make(chan struct{})}
+		cache.inFlight[url] = flight
+		generation := cache.generation
+		cache.mutex.Unlock()
+
+		flight.response = send()
+		flight.abandoned = flight.response.err != nil && ctx.Err() != nil
+
+		cache.mutex.Lock()
+		delete(cache.inFlight, url)
+		if flight.response.err == nil &&
+			flight.response.statusCode >= 200 && flight.response.statusCode <= 299 &&
+			generation == cache.generation {
+			flight.response.expires = time.Now().Add(cache.ttl)
+			cache.responses[url] = flight.response
+		}
+		cache.mutex.Unlock()
+		close(flight.done)
+
+		return flight.response
+	}
+}
+
+// invalidate drops every cached response of the collection the given request
+// path belongs to.  The collection is the first path segment after the API
+// URL prefix, ie: "/api/v2/templates/1234" invalidates all URLs under
+// "/api/v2/templates".
+func (cache *responseCache) invalidate(apiPrefix string, path string) {
+	collection := strings.TrimPrefix(path, apiPrefix)
+	collection = strings.TrimPrefix(collection, "/")
+	if idx := strings.Index(collection, "/"); idx >= 0 {
+		collection = collection[:idx]
+	}
+	collectionPath := apiPrefix + "/" + collection
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.generation++
+	for url := range cache.responses {
+		if urlHasPathPrefix(url, collectionPath) {
+			log.Debugf("Invalidating cached [GET %s]", url)
+			delete(cache.responses, url)
+		}
+	}
+}
+
+// urlHasPathPrefix reports whether the path of the absolute URL is the given
+// path or a sub-path of it.
+func urlHasPathPrefix(url string, path string) bool {
+	// strip scheme and host: "https://host:port/api/v2/..." => "/api/v2/..."
+	if idx := strings.Index(url, "://"); idx >= 0 {
+		url = url[idx+3:]
+		if slash := strings.Index(url, "/"); slash >= 0 {
+			url = url[slash:]
+		} else {
+			url = "/"
+		}
+	}
+	if !strings.HasPrefix(url, path) {
+		return false
+	}
+	rest := url[len(path):]
+	return rest == "" || rest[0] == '/' || rest[0] == '?'
+}
@@ -0,0 +1,348 @@
+// Package cassette provides an http.RoundTripper recording the interactions
+// of an api.Client with Turbonomic into cassette files, and replaying them
+// without network access.  Cassettes are used to regression-test the
+// provider against real Turbonomic responses in CI.
+//
+// Credentials are redacted before a cassette is written: session cookies,
+// authorization headers, passwords, client secrets and access tokens never
+// reach the file.  Only the path and query of each URL are recorded, so a
+// cassette can be replayed against any server URL.
+//
+// Typical usage:
+//
+//   recorder, err := cassette.New("testdata/template_crud.json", cassette.ModeReplay, nil)
+//   ...
+//   client := api.NewClient(server, false, credentials, api.ClientOptions{
+//     Transport: recorder,
+//   })
+//   ...
+//   err = recorder.Stop()
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/foo/terraform-provider-utils/log"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+)
+
+// Mode - whether a Recorder records or replays interactions
+type Mode string
+
+const (
+	// ModeReplay - serve responses from the cassette.  Requests without a
+	// recorded interaction fail.  No request reaches the network.
+	ModeReplay Mode = "replay"
+	// ModeRecord - send requests to the server and record the interactions.
+	// The cassette is written by Recorder.Stop.
+	ModeRecord Mode = "record"
+)
+
+const (
+	// ModeEnv - environment variable selecting the mode of the cassettes used
+	// by the tests.  See ModeFromEnv.
+	ModeEnv = "TURBO_CASSETTE_MODE"
+	// Redacted - placeholder written in place of credentials
+	Redacted = "REDACTED"
+)
+
+var (
+	// redactedFormFields - form fields holding credentials in login and
+	// token requests
+	redactedFormFields = []string{"username", "password", "client_id", "client_secret"}
+	// tokenPattern - tokens in OAuth2 token responses
+	tokenPattern = regexp.MustCompile(`"(access_token|refresh_token|id_token)"\s*:\s*"[^"]*"`)
+	// cookiePattern - value of a Set-Cookie header, ie: "JSESSIONID=abc; Path=/"
+	cookiePattern = regexp.MustCompile(`^([^=;]+)=[^;]*`)
+)
+
+// ModeFromEnv returns the mode selected by the TURBO_CASSETTE_MODE
+// environment variable, defaulting to ModeReplay.
+func ModeFromEnv() Mode {
+	if Mode(os.Getenv(ModeEnv)) == ModeRecord {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// Cassette - the interactions recorded in a cassette file, in the order they
+// were recorded
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction - a recorded request and the response of the server
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request - a recorded request.  Headers are not recorded.
+type Request struct {
+	Method string `json:"method"`
+	// Path and query of the request URL, ie: "/api/v2/templates?limit=500"
+	URL string `json:"url"`
+	// Redacted request body
+	Body string `json:"body,omitempty"`
+}
+
+// Response - a recorded response
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	// Redacted response body
+	Body string `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper recording or replaying a cassette.  It is
+// safe for concurrent use.
+type Recorder struct {
+	mode Mode
+	// Path of the cassette file
+	path string
+	// Transport performing the actual round trips in ModeRecord
+	next http.RoundTripper
+
+	mutex    sync.Mutex
+	cassette Cassette
+	// Whether each interaction was already replayed
+	replayed []bool
+}
+
+// New returns a Recorder for the cassette file at path.  In ModeReplay the
+// cassette is loaded immediately.  In ModeRecord requests are sent through
+// next, which defaults to a pooled cleanhttp transport.
+func New(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	log.Tracef("turbonomic/api/cassette/cassette.go#New")
+
+	recorder := &Recorder{
+		mode: mode,
+		path: path,
+		next: next,
+	}
+
+	switch mode {
+	case ModeRecord:
+		if recorder.next == nil {
+			recorder.next = cleanhttp.DefaultPooledTransport()
+		}
+	case ModeReplay:
+		cassetteBytes, readErr := ioutil.ReadFile(path)
+		if readErr != nil {
+			return nil, fmt.Errorf("Unable to read cassette [%s]: %s", path, readErr)
+		}
+		if jsonDecErr := json.Unmarshal(cassetteBytes, &recorder.cassette); jsonDecErr != nil {
+			return nil, fmt.Errorf("Invalid cassette [%s]: %s", path, jsonDecErr)
+		}
+		recorder.replayed = make([]bool, len(recorder.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("Unknown cassette mode [%s]", mode)
+	}
+
+	return recorder, nil
+}
+
+// Mode returns the mode of the recorder
+func (recorder *Recorder) Mode() Mode {
+	return recorder.mode
+}
+
+// Stop writes the recorded interactions to the cassette file.  Does nothing
+// in ModeReplay.
+func (recorder *Recorder) Stop() error {
+	if recorder.mode != ModeRecord {
+		return nil
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	cassetteBytes, jsonEncErr := json.MarshalIndent(recorder.cassette, "", "  ")
+	if jsonEncErr != nil {
+		return jsonEncErr
+	}
+	if mkdirErr := os.MkdirAll(filepath.Dir(recorder.path), 0755); mkdirErr != nil {
+		return mkdirErr
+	}
+	log.Infof(
+		"Writing [%d] interactions to cassette [%s]",
+		len(recorder.cassette.Interactions),
+		recorder.path,
+	)
+	return ioutil.WriteFile(recorder.path, append(cassetteBytes, '\n'), 0644)
+}
+
+// -----------------------------------------------------------------------------
+// Round Trips
+// -----------------------------------------------------------------------------
+
+// RoundTrip implements http.RoundTripper
+func (recorder *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, readErr := readRequestBody(req)
+	if readErr != nil {
+		return nil, readErr
+	}
+	recorded := Request{
+		Method: req.Method,
+		URL:    req.URL.RequestURI(),
+		Body:   redactRequestBody(req.Header.Get("Content-Type"), reqBody),
+	}
+
+	if recorder.mode == ModeReplay {
+		return recorder.replay(req, recorded)
+	}
+	return recorder.record(req, recorded)
+}
+
+// replay returns the recorded response of the first interaction matching the
+// request that has not been replayed yet.  Requests are matched on their
+// method and URL.  A request sent more often than it was recorded (ie: a
+// status poll) replays the response of its last recorded interaction.
+func (recorder *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	match := -1
+	for idx, interaction := range recorder.cassette.Interactions {
+		if interaction.Request.Method != recorded.Method ||
+			interaction.Request.URL != recorded.URL {
+			continue
+		}
+		match = idx
+		if !recorder.replayed[idx] {
+			break
+		}
+	}
+	if match < 0 {
+		return nil, fmt.Errorf(
+			"No interaction recorded in cassette [%s] for [%s %s]",
+			recorder.path,
+			recorded.Method,
+			recorded.URL,
+		)
+	}
+	recorder.replayed[match] = true
+
+	log.Debugf("Replaying [%s %s] from cassette [%s]", recorded.Method, recorded.URL, recorder.path)
+	return recorder.cassette.Interactions[match].Response.httpResponse(req), nil
+}
+
+// record sends the request to the server and records the redacted
+// interaction.
+func (recorder *Recorder) record(req *http.Request, recorded Request) (*http.Response, error) {
+	resp, respErr := recorder.next.RoundTrip(req)
+	if respErr != nil {
+		return nil, respErr
+	}
+	defer resp.Body.Close()
+
+	respBody, readErr := ioutil.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	interaction := Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeader(resp.Header),
+			Body:       tokenPattern.ReplaceAllString(string(respBody), `"$1":"`+Redacted+`"`),
+		},
+	}
+	recorder.mutex.Lock()
+	recorder.cassette.Interactions = append(recorder.cassette.Interactions, interaction)
+	recorder.mutex.Unlock()
+
+	// hand the unredacted response to the client
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// httpResponse builds the response to req from the recorded response
+func (recorded Response) httpResponse(req *http.Request) *http.Response {
+	header := http.Header{}
+	for key, values := range recorded.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Redaction
+// -----------------------------------------------------------------------------
+
+// readRequestBody returns the request body, leaving the request ready to be
+// sent.
+func readRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	reqBody, readErr := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if readErr != nil {
+		return "", readErr
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	return string(reqBody), nil
+}
+
+// redactRequestBody redacts the credentials of form-encoded login and token
+// requests
+func redactRequestBody(contentType string, body string) string {
+	if !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return body
+	}
+	form, parseErr := url.ParseQuery(body)
+	if parseErr != nil {
+		return Redacted
+	}
+	for _, field := range redactedFormFields {
+		if _, ok := form[field]; ok {
+			form.Set(field, Redacted)
+		}
+	}
+	return form.Encode()
+}
+
+// redactHeader returns a copy of the response header without cookie values
+func redactHeader(header http.Header) http.Header {
+	redacted := http.Header{}
+	for key, values := range header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Set-Cookie":
+			for _, value := range values {
+				redacted.Add(key, cookiePattern.ReplaceAllString(value, "${1}="+Redacted))
+			}
+		case "Authorization", "Date":
+			// credentials, or irrelevant to the replay
+		default:
+			redacted[key] = append([]string(nil), values...)
+		}
+	}
+	return redacted
+}
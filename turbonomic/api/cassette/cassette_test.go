@@ -0,0 +1,106 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc(api.APIURLPrefix+"/"+api.LoginPrefix, func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "secret-session", Path: "/"})
+		fmt.Fprint(w, `{"username":"admin"}`)
+	})
+	mux.HandleFunc(api.APIURLPrefix+"/"+api.ReservationsPrefix+"/1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		status := "IN_PROGRESS"
+		if polls > 1 {
+			status = "RESERVED"
+		}
+		fmt.Fprintf(w, `{"uuid":"1","status":%q}`, status)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	credentials := api.ClientCredentials{Username: "admin", Password: "secret-password"}
+	newClient := func(serverURL string, recorder *Recorder) *api.Client {
+		parsed, _ := url.Parse(serverURL)
+		return api.NewClient(*parsed, false, credentials, api.ClientOptions{Transport: recorder})
+	}
+
+	// -- record --
+	recorder, err := New(path, ModeRecord, nil)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	client := newClient(server.URL, recorder)
+	for i := 0; i < 2; i++ {
+		if _, err := client.ReadReservation(context.Background(), "1"); err != nil {
+			t.Fatalf("ReadReservation: %s", err)
+		}
+	}
+	if err := recorder.Stop(); err != nil {
+		t.Fatalf("Stop: %s", err)
+	}
+
+	cassetteBytes, _ := ioutil.ReadFile(path)
+	for _, secret := range []string{"secret-session", "secret-password", "admin"} {
+		if strings.Contains(string(cassetteBytes), "="+secret) {
+			t.Errorf("cassette contains [%s]:\n%s", secret, cassetteBytes)
+		}
+	}
+
+	// -- replay, against a server that no longer exists --
+	server.Close()
+	recorder, err = New(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	client = newClient("https://turbonomic.invalid", recorder)
+	expected := []string{"IN_PROGRESS", "RESERVED", "RESERVED"}
+	for _, status := range expected {
+		reservation, err := client.ReadReservation(context.Background(), "1")
+		if err != nil {
+			t.Fatalf("ReadReservation: %s", err)
+		}
+		if reservation.Status != status {
+			t.Fatalf("expected status [%s], got [%s]", status, reservation.Status)
+		}
+	}
+
+	if _, err := client.ReadReservation(context.Background(), "2"); err == nil {
+		t.Fatalf("expected an error for a request missing from the cassette")
+	}
+}
+
+func TestRedactRequestBody(t *testing.T) {
+	form := "client_id=terraform&client_secret=s3cr3t&grant_type=client_credentials"
+	redacted := redactRequestBody("application/x-www-form-urlencoded", form)
+	if strings.Contains(redacted, "s3cr3t") || strings.Contains(redacted, "terraform") {
+		t.Errorf("credentials not redacted: [%s]", redacted)
+	}
+	if !strings.Contains(redacted, "grant_type=client_credentials") {
+		t.Errorf("expected other fields to be kept: [%s]", redacted)
+	}
+
+	body := `{"displayName":"password=unchanged"}`
+	if redactRequestBody("application/json", body) != body {
+		t.Errorf("expected JSON bodies to be kept")
+	}
+
+	token := `{"access_token": "abc.def", "token_type": "bearer"}`
+	if tokenPattern.ReplaceAllString(token, `"$1":"`+Redacted+`"`) != `{"access_token":"REDACTED", "token_type": "bearer"}` {
+		t.Errorf("access token not redacted")
+	}
+}
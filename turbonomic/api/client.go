@@ -1,14 +1,19 @@
 package api
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/foo/terraform-provider-utils/log"
 
@@ -19,7 +24,8 @@ const (
 	// APIURLPrefix - prefix to the path component
 	// of the URL on every Turbonomic API call.
 	// The client hepler functions utilize this to automatically
-	// create endpoint URLs.
+	// create endpoint URLs.  Used when the ClientOptions do not set an
+	// APIBasePath.
 	APIURLPrefix = "/api/v2"
 )
 
@@ -27,11 +33,163 @@ const (
 // Client / Server Configuration
 // ----------------------------------------------------------------------------
 
+// Supported methods for authenticating the client against Turbonomic
+const (
+	// AuthMethodSession logs in once through the login endpoint and reuses
+	// the session cookie on subsequent requests.
+	AuthMethodSession = "session"
+	// AuthMethodBasic sends the username and password as HTTP basic auth on
+	// every request.
+	AuthMethodBasic = "basic"
+	// AuthMethodOAuth2 obtains access tokens from the token endpoint using the
+	// OAuth2 client credentials grant and refreshes them before they expire.
+	AuthMethodOAuth2 = "oauth2"
+	// AuthMethodToken sends a static, pre-issued bearer token on every
+	// request.
+	AuthMethodToken = "token"
+)
+
 // ClientCredentials used to authenticate the client against the remote server - in
 // this case, the Turbonomic API
 type ClientCredentials struct {
+	// One of the AuthMethodXxx constants.  When left empty, the method is
+	// inferred from the credentials that are set: Token selects
+	// AuthMethodToken, ClientID selects AuthMethodOAuth2, and anything else
+	// selects AuthMethodSession.
+	AuthMethod string
+
+	// -- AuthMethodSession, AuthMethodBasic --
 	Username string
 	Password string
+
+	// -- AuthMethodOAuth2 --
+	ClientID     string
+	ClientSecret string
+	// URL of the OAuth2 token endpoint.  Defaults to DefaultTokenPath on the
+	// Turbonomic server when left empty.
+	TokenURL string
+
+	// -- AuthMethodToken --
+	Token string
+}
+
+// authMethod returns the configured authentication method, inferring it
+// from the supplied credentials when it has not been set explicitly.
+func (creds ClientCredentials) authMethod() string {
+	switch {
+	case creds.AuthMethod != "":
+		return creds.AuthMethod
+	case creds.Token != "":
+		return AuthMethodToken
+	case creds.ClientID != "":
+		return AuthMethodOAuth2
+	default:
+		return AuthMethodSession
+	}
+}
+
+// Validate checks that the credentials required by the authentication method
+// are set.
+func (creds ClientCredentials) Validate() error {
+	method := creds.authMethod()
+	switch method {
+	case AuthMethodSession, AuthMethodBasic:
+		if creds.Username == "" || creds.Password == "" {
+			return fmt.Errorf(
+				"Authentication method [%s] requires a username and password",
+				method,
+			)
+		}
+	case AuthMethodOAuth2:
+		if creds.ClientID == "" || creds.ClientSecret == "" {
+			return fmt.Errorf(
+				"Authentication method [%s] requires a client ID and client secret",
+				method,
+			)
+		}
+	case AuthMethodToken:
+		if creds.Token == "" {
+			return fmt.Errorf(
+				"Authentication method [%s] requires a token",
+				method,
+			)
+		}
+	default:
+		return fmt.Errorf("Unsupported authentication method: [%s]", method)
+	}
+	return nil
+}
+
+// ClientOptions configures optional behavior of the Client.  The zero value
+// is valid and disables all optional behavior.
+type ClientOptions struct {
+	// Maximum number of times a request is retried after a transient failure.
+	// Zero disables retries.
+	MaxRetries int
+	// Initial wait between retries.  The wait doubles after every retry.
+	// Defaults to DefaultRetryMinWait.
+	RetryMinWait time.Duration
+	// Upper bound for the wait between retries, including waits requested by
+	// the server through the Retry-After header.  Defaults to
+	// DefaultRetryMaxWait.
+	RetryMaxWait time.Duration
+	// Whether or not to also retry non-idempotent requests (POST, PATCH).
+	// Only enable this if duplicate requests are harmless.
+	RetryNonIdempotent bool
+	// Average number of requests per second sent to Turbonomic, shared by
+	// every request made through the client.  Zero disables rate limiting.
+	MaxRequestsPerSecond float64
+	// Number of requests that may be sent in a burst above
+	// MaxRequestsPerSecond.  Defaults to 1.
+	RequestBurst int
+	// Maximum number of requests in flight at once.  Zero means unlimited.
+	MaxConcurrentRequests int
+	// Number of objects requested per page from list endpoints.  Defaults to
+	// DefaultPageSize.
+	PageSize int
+	// How long successful GET responses are served from an in-memory cache.
+	// Concurrent identical GETs are coalesced into a single request and
+	// mutating requests invalidate the cached responses of the collection
+	// they modify.  Zero disables the cache.
+	CacheTTL time.Duration
+	// Context bounding the lifetime of the client.  When it is done, every
+	// in-flight request is canceled, regardless of the context the request
+	// was created with.  Used to abort requests when Terraform is
+	// interrupted.  Optional.
+	StopContext context.Context
+	// CA bundle, client certificate and TLS settings used to connect to the
+	// server.  Should be validated with TLSOptions.Validate beforehand.
+	TLS TLSOptions
+	// Path prefix of every API endpoint, ie: "/turbo/api/v2" when Turbonomic
+	// is mounted under /turbo by a reverse proxy.  Defaults to APIURLPrefix.
+	APIBasePath string
+	// Proxy every request is sent through.  Defaults to the proxy configured
+	// by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy *url.URL
+	// Maximum duration of a single HTTP request, including reading the
+	// response body.  Zero means no timeout other than the request context.
+	RequestTimeout time.Duration
+	// Maximum duration to establish a TCP connection to the server.
+	// Defaults to the cleanhttp transport's default.
+	DialTimeout time.Duration
+	// Transport performing the round trips, ie: a cassette.Recorder in tests.
+	// Defaults to a pooled cleanhttp transport configured with the TLS,
+	// Proxy and DialTimeout options, which are ignored when a Transport is
+	// set.  Rate and concurrency limits still apply.
+	Transport http.RoundTripper
+	// Format of the log entry written for every HTTP exchange, one of the
+	// LogFormatXxx constants.  Defaults to LogFormatText.  Credentials are
+	// redacted from the entries.
+	LogFormat string
+	// Writer the LogFormatJSON entries are written to.  Defaults to
+	// os.Stderr.
+	LogOutput io.Writer
+	// Maximum number of bytes of each request and response body included in
+	// the log entries.  Zero omits the bodies and the headers.
+	LogMaxBodySize int
+	// Middlewares wrapping every request sent through the client, outermost
+	// first.  See Middleware.
+	Middlewares []Middleware
 }
 
 // Client - REST client implementation for interaction with Turbonomic
@@ -40,10 +198,33 @@ type Client struct {
 	Server url.URL
 	// Set of credentials to authenticate the client
 	credentials ClientCredentials
+	// Optional behavior of the client, such as retries
+	options ClientOptions
 	// Instance of the HTTP client used to communicate with the webservice.  After
 	// the intial setup, the client should never modify or interact directly with
 	// the underlying HTTP client and should instead use the helper functions.
 	httpClient *http.Client
+	// Guards the authentication state below.  Logins and token requests are
+	// serialized so concurrent requests that all see an expired session or
+	// token only authenticate once.
+	authMutex sync.Mutex
+	// Whether or not a session has been established with the server
+	sessionActive bool
+	// Cached OAuth2 access token and the time at which it expires
+	accessToken       string
+	accessTokenExpiry time.Time
+	// Incremented every time the client logs in or obtains a new token.  Used
+	// to detect whether another request already re-authenticated.
+	authGeneration uint64
+	// Cache of GET responses.  Nil when ClientOptions.CacheTTL is zero.
+	cache *responseCache
+	// Guards the server version below
+	versionMutex sync.RWMutex
+	// Product version of the server recorded by DetectVersion.  Nil until
+	// the version is detected.
+	version *ServerVersion
+	// Sends the requests through ClientOptions.Middlewares
+	handler Handler
 }
 
 // NewClient - Initializes a new Client struct for use by the provider.
@@ -59,7 +240,9 @@ type Client struct {
 //   configuration options.
 // credentials
 //   The API credentials needed to authenticate
-func NewClient(server url.URL, insecure bool, credentials ClientCredentials) *Client {
+// options
+//   Optional behavior of the client.  See ClientOptions.
+func NewClient(server url.URL, insecure bool, credentials ClientCredentials, options ClientOptions) *Client {
 
 	log.Tracef(
 		"Turbonomic.client.go#NewClient\n"+
@@ -70,19 +253,81 @@ func NewClient(server url.URL, insecure bool, credentials ClientCredentials) *Cl
 	)
 
 	// Initialize the HTTP client for use by the provider.  The insecure flag
-	// from the provider config is used when configuring the TLS settings of
-	// the HTTP client.
+	// and TLS options from the provider config are used when configuring the
+	// TLS settings of the HTTP client.  The pooled cleanhttp transport keeps
+	// its defaults (proxy from the environment, dial and handshake timeouts,
+	// keep-alives), since the client is shared by every resource.
 	cleanClient := cleanhttp.DefaultClient()
-	transCfg := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+	cleanClient.Timeout = options.RequestTimeout
+	tlsCfg, tlsErr := options.TLS.tlsConfig(insecure)
+	if tlsErr != nil {
+		// NOTE(ALL): the options are validated by the provider before the
+		//   client is created.  Fall back to the insecure flag alone so the
+		//   failure surfaces as a TLS error on the first request.
+		log.Errorf("Invalid TLS options, ignoring them: %s", tlsErr)
+		tlsCfg = &tls.Config{InsecureSkipVerify: insecure}
+	}
+	transCfg := cleanhttp.DefaultPooledTransport()
+	transCfg.TLSClientConfig = tlsCfg
+	if options.Proxy != nil {
+		transCfg.Proxy = http.ProxyURL(options.Proxy)
+	}
+	if options.DialTimeout > 0 {
+		transCfg.DialContext = (&net.Dialer{
+			Timeout:   options.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
 	}
-	cleanClient.Transport = transCfg
-	// Initialize and return the unauthenticated client.
+	var baseTransport http.RoundTripper = transCfg
+	if options.Transport != nil {
+		baseTransport = options.Transport
+	}
+	// Log the exchanges with the server, inside the rate and concurrency
+	// limits so the latency excludes the time spent waiting for them.
+	baseTransport = newLoggingTransport(options, baseTransport)
+	cleanClient.Transport = baseTransport
+	// Every round trip goes through the rate and concurrency limits, which
+	// are shared by all resources using the client.
+	if options.MaxRequestsPerSecond > 0 || options.MaxConcurrentRequests > 0 {
+		throttled := &throttledTransport{next: baseTransport}
+		if options.MaxRequestsPerSecond > 0 {
+			throttled.rateLimiter = newRateLimiter(
+				options.MaxRequestsPerSecond,
+				options.RequestBurst,
+			)
+		}
+		if options.MaxConcurrentRequests > 0 {
+			throttled.concurrencyLimiter = newConcurrencyLimiter(options.MaxConcurrentRequests)
+		}
+		cleanClient.Transport = throttled
+	}
+	// The cookie jar holds the session cookie returned by the login
+	// endpoint.  cookiejar.New only errors on an invalid PublicSuffixList.
+	jar, _ := cookiejar.New(nil)
+	cleanClient.Jar = jar
+	credentials.AuthMethod = credentials.authMethod()
+	if options.RetryMinWait <= 0 {
+		options.RetryMinWait = DefaultRetryMinWait
+	}
+	if options.RetryMaxWait <= 0 {
+		options.RetryMaxWait = DefaultRetryMaxWait
+	}
+	if options.PageSize <= 0 {
+		options.PageSize = DefaultPageSize
+	}
+	options.APIBasePath = normalizeBasePath(options.APIBasePath)
+	// Initialize and return the unauthenticated client.  Sessions and access
+	// tokens are obtained lazily on the first request.
 	client := Client{
 		httpClient:  cleanClient,
 		Server:      server,
 		credentials: credentials,
+		options:     options,
+	}
+	if options.CacheTTL > 0 {
+		client.cache = newResponseCache(options.CacheTTL)
 	}
+	client.handler = chainMiddlewares(client.dispatch, options.Middlewares)
 	return &client
 }
 
@@ -102,8 +347,13 @@ func NewClient(server url.URL, insecure bool, credentials ClientCredentials) *Cl
 //   User-Agent
 //   ACCEPT
 //   Content-Type
-//   Authorization
 //
+// The Authorization header (or session cookie) is added by Send() according
+// to the client's authentication method.
+//
+// ctx
+//   Context of the request.  Canceling the context or exceeding its deadline
+//   aborts the request, including any pending retries.
 // method
 //   The HTTP Verb to use.  This should correspond to a 'Method*' constant
 //   from 'net/http'.
@@ -114,7 +364,7 @@ func NewClient(server url.URL, insecure bool, credentials ClientCredentials) *Cl
 //   prefix to the endpoint.
 // body
 //   Functions exactly like net/http/NewRequest()
-func (client *Client) NewRequest(method string, endpoint string, body io.Reader) (*http.Request, error) {
+func (client *Client) NewRequest(ctx context.Context, method string, endpoint string, body io.Reader) (*http.Request, error) {
 
 	log.Tracef(
 		"Turbonomic.client.go#NewRequest\n"+
@@ -132,9 +382,9 @@ func (client *Client) NewRequest(method string, endpoint string, body io.Reader)
 	// Build the URL for the request
 	reqURL := client.Server
 	if strings.HasPrefix(endpoint, "/") {
-		reqURL.Path = APIURLPrefix + endpoint
+		reqURL.Path = client.options.APIBasePath + endpoint
 	} else {
-		reqURL.Path = APIURLPrefix + "/" + endpoint
+		reqURL.Path = client.options.APIBasePath + "/" + endpoint
 	}
 
 	log.Debugf(
@@ -143,7 +393,8 @@ func (client *Client) NewRequest(method string, endpoint string, body io.Reader)
 	)
 
 	// Create the request object, bubble up errors if any were encountered
-	req, reqErr := http.NewRequest(
+	req, reqErr := http.NewRequestWithContext(
+		ctx,
 		strings.ToUpper(method),
 		reqURL.String(),
 		body,
@@ -160,10 +411,20 @@ func (client *Client) NewRequest(method string, endpoint string, body io.Reader)
 	req.Header.Add("User-Agent", "terraform-provider-turbonomic")
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Content-Type", "application/json")
-	req.SetBasicAuth(client.credentials.Username, client.credentials.Password)
 	return req, nil
 }
 
+// Helper function used to normalize the API base path to a path with a
+// leading slash and no trailing slash, ie: "turbo/api/v2/" => "/turbo/api/v2".
+// An empty path defaults to APIURLPrefix.
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return APIURLPrefix
+	}
+	return "/" + basePath
+}
+
 // Helper function used to determine if an HTTP request method is valid.
 //
 // NOTE(ALL): Go's HTTP client does not support sending a request with
@@ -195,6 +456,14 @@ func isValidRequestMethod(method string) bool {
 // the StatusCode, response. Serves as a facade to the Client's underlying
 // HTTP client.
 //
+// The request is authenticated according to the client's authentication
+// method.  If the server rejects the request because the session or access
+// token expired, the client re-authenticates and replays the request once.
+//
+// Transient failures (connection errors and 429, 502, 503, 504 responses) are
+// retried with exponential backoff according to the client's retry options.
+// By default only idempotent methods are retried.
+//
 // If an error is encountered when reading the server's response, the returned
 // StatusCode will be -1.  If an error is encountered during any step of the
 // the send and response parsing, an empty slice will be returned as the
@@ -205,22 +474,156 @@ func isValidRequestMethod(method string) bool {
 func (client *Client) Send(request *http.Request) (int, []byte, error) {
 	log.Tracef("Turbonomic.client.go#Send")
 
+	statusCode, _, respBody, sendErr := client.send(request)
+	return statusCode, respBody, sendErr
+}
+
+// send implements Send and additionally returns the response headers.
+func (client *Client) send(request *http.Request) (int, http.Header, []byte, error) {
 	emptySlice := []byte{}
 
 	if request == nil {
 		log.Errorf("Client trying to send a nil request")
-		return -1, emptySlice, fmt.Errorf("Client trying to send a nil request")
+		return -1, nil, emptySlice, fmt.Errorf("Client trying to send a nil request")
+	}
+
+	if client.options.StopContext != nil {
+		ctx, cancel := context.WithCancel(request.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-client.options.StopContext.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		request = request.WithContext(ctx)
+	}
+
+	resp, sendErr := client.handler(request)
+	if resp == nil {
+		return -1, nil, emptySlice, sendErr
+	}
+	return resp.StatusCode, resp.Header, resp.Body, sendErr
+}
+
+// dispatch is the innermost Handler of the middleware chain.  It serves the
+// request from the cache when possible, or sends it with retries.
+func (client *Client) dispatch(request *http.Request) (*Response, error) {
+	if client.cache == nil {
+		return newResponse(client.sendWithRetries(request))
+	}
+	if request.Method == http.MethodGet {
+		cached := client.cache.get(request.Context(), request.URL.String(), func() cachedResponse {
+			statusCode, header, respBody, sendErr := client.sendWithRetries(request)
+			return cachedResponse{
+				statusCode: statusCode,
+				header:     header,
+				body:       respBody,
+				err:        sendErr,
+			}
+		})
+		return newResponse(cached.statusCode, cached.header, cached.body, cached.err)
+	}
+	statusCode, header, respBody, sendErr := client.sendWithRetries(request)
+	if sendErr == nil && statusCode >= 200 && statusCode <= 299 {
+		client.cache.invalidate(client.options.APIBasePath, request.URL.Path)
+	}
+	return newResponse(statusCode, header, respBody, sendErr)
+}
+
+// newResponse adapts the results of sendWithRetries to a Handler's.  The
+// response is nil if no response was received.
+func newResponse(statusCode int, header http.Header, body []byte, err error) (*Response, error) {
+	if statusCode < 0 {
+		return nil, err
+	}
+	return &Response{StatusCode: statusCode, Header: header, Body: body}, err
+}
+
+// sendWithRetries sends the request, retrying it after transient failures
+// as allowed by the client options.
+func (client *Client) sendWithRetries(request *http.Request) (int, http.Header, []byte, error) {
+	emptySlice := []byte{}
+
+	for attempt := 0; ; attempt++ {
+		statusCode, header, respBody, sendErr := client.sendAttempt(request)
+
+		wait, retry := client.retryWait(request, attempt, statusCode, header, sendErr)
+		if !retry {
+			if sendErr != nil {
+				return statusCode, header, emptySlice, sendErr
+			}
+			return statusCode, header, respBody, nil
+		}
+
+		reason := fmt.Sprintf("statusCode [%d]", statusCode)
+		if sendErr != nil {
+			reason = sendErr.Error()
+		}
+		log.Infof(
+			"Retrying [%s %s] in [%s] (retry %d of %d): %s",
+			request.Method,
+			request.URL,
+			wait,
+			attempt+1,
+			client.options.MaxRetries,
+			reason,
+		)
+		if sleepErr := sleepContext(request.Context(), wait); sleepErr != nil {
+			return -1, nil, emptySlice, sleepErr
+		}
+
+		request.Header.Del("Cookie")
+		if rewindErr := rewindRequestBody(request); rewindErr != nil {
+			return -1, nil, emptySlice, rewindErr
+		}
+	}
+}
+
+// sendAttempt authorizes and sends the request once, re-authenticating and
+// replaying it if the server rejects the credentials, and reads the response.
+func (client *Client) sendAttempt(request *http.Request) (int, http.Header, []byte, error) {
+	emptySlice := []byte{}
+
+	generation, authErr := client.authorize(request)
+	if authErr != nil {
+		return -1, nil, emptySlice, authErr
 	}
 
 	// Send the request to the server
 	resp, respErr := client.httpClient.Do(request)
+	if respErr == nil &&
+		resp.StatusCode == http.StatusUnauthorized &&
+		client.canReauthenticate() {
+		log.Infof(
+			"Turbonomic rejected the credentials, re-authenticating and replaying [%s %s]",
+			request.Method,
+			request.URL,
+		)
+		// Drain and close the rejected response so the connection can be
+		// reused for the replay.
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		// NOTE(ALL): http.Client copies the cookie jar's cookies into the
+		//   request headers when sending.  Drop them so the replay carries
+		//   only the renewed session cookie.
+		request.Header.Del("Cookie")
+		if authErr = client.reauthenticate(request, generation); authErr != nil {
+			return -1, nil, emptySlice, authErr
+		}
+		if rewindErr := rewindRequestBody(request); rewindErr != nil {
+			return -1, nil, emptySlice, rewindErr
+		}
+		resp, respErr = client.httpClient.Do(request)
+	}
 	if respErr != nil {
 		log.Errorf(
 			"Error encountered when sending HTTP request to server\n"+
 				"  Error: %s",
 			respErr.Error(),
 		)
-		return -1, emptySlice, respErr
+		return -1, nil, emptySlice, respErr
 	}
 	// NOTE(ALL): Golang stdlib dictates that it is the caller's resposibility
 	//   to close the response body.  See net/http Response type for more
@@ -235,10 +638,10 @@ func (client *Client) Send(request *http.Request) (int, []byte, error) {
 				"  Error: %s",
 			readErr.Error(),
 		)
-		return resp.StatusCode, emptySlice, readErr
+		return resp.StatusCode, resp.Header, emptySlice, readErr
 	}
 
-	return resp.StatusCode, respBody, nil
+	return resp.StatusCode, resp.Header, respBody, nil
 }
 
 // SendAndParse - Sends an HTTP request generated by Client.NewRequest() and parses the server's
@@ -246,42 +649,30 @@ func (client *Client) Send(request *http.Request) (int, []byte, error) {
 // response parsing, the function returns an error.  Otherwise, the server's
 // response is unmarshalled into the supplied interface (if the interface
 // is not nil).
+//
+// If the server responds with a non-2xx status code, the returned error is
+// an *APIError.
 func (client *Client) SendAndParse(req *http.Request, obj interface{}) error {
 	log.Tracef("Turbonomic.client.go#SendAndParse")
 
-	statusCode, respBody, sendErr := client.Send(req)
+	_, parseErr := client.sendAndParse(req, obj)
+	return parseErr
+}
+
+// sendAndParse implements SendAndParse and additionally returns the response
+// headers.
+func (client *Client) sendAndParse(req *http.Request, obj interface{}) (http.Header, error) {
+	statusCode, header, respBody, sendErr := client.send(req)
 	if sendErr != nil {
-		return sendErr
+		return header, sendErr
 	}
 
-	log.Debugf(
-		"server response:{\n"+
-			"  endpoint:   [%s]\n"+
-			"  method:     [%s]\n"+
-			"  statusCode: [%d]\n"+
-			"  respBody:   [%s]\n"+
-			"}",
-		req.URL,
-		req.Method,
-		statusCode,
-		respBody,
-	)
-
 	if statusCode < 200 || statusCode > 299 {
-		return fmt.Errorf(
-			"HTTP Error:{\n"+
-				"  endpoint:   [%s]\n"+
-				"  statusCode: [%d]\n"+
-				"  respBody:   [%s]\n"+
-				"}",
-			req.URL,
-			statusCode,
-			respBody,
-		)
+		return header, newAPIError(req, statusCode, respBody)
 	}
 
 	if obj != nil {
-		return json.Unmarshal(respBody, &obj)
+		return header, json.Unmarshal(respBody, &obj)
 	}
-	return nil
+	return header, nil
 }
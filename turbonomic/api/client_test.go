@@ -0,0 +1,1197 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testAuthServer is a stand-in for the Turbonomic login, OAuth2 token and API
+// endpoints.  Only requests carrying the most recently issued session cookie
+// or access token are accepted.
+type testAuthServer struct {
+	*httptest.Server
+
+	mutex        sync.Mutex
+	issued       int
+	current      string
+	logins       int
+	tokenFetches int
+}
+
+func newTestAuthServer(t *testing.T) *testAuthServer {
+	s := &testAuthServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIURLPrefix+"/"+LoginPrefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("username") != "admin" || r.FormValue("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.mutex.Lock()
+		s.logins++
+		session := s.issue()
+		s.mutex.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: session, Path: "/"})
+		fmt.Fprint(w, `{"username":"admin"}`)
+	})
+	mux.HandleFunc(DefaultTokenPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "client_credentials" ||
+			r.FormValue("client_id") != "terraform" ||
+			r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.mutex.Lock()
+		s.tokenFetches++
+		token := s.issue()
+		s.mutex.Unlock()
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer","expires_in":3600}`, token)
+	})
+	mux.HandleFunc(APIURLPrefix+"/"+TemplatesPrefix, func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		current := s.current
+		s.mutex.Unlock()
+		cookie, _ := r.Cookie("JSESSIONID")
+		if r.Header.Get("Authorization") != "Bearer "+current &&
+			(cookie == nil || cookie.Value != current) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[{"uuid":"1","displayName":"template"}]`)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// issue returns a new credential and revokes the previous one.  The caller
+// must hold the mutex.
+func (s *testAuthServer) issue() string {
+	s.issued++
+	s.current = fmt.Sprintf("credential-%d", s.issued)
+	return s.current
+}
+
+// expire revokes the current session or access token
+func (s *testAuthServer) expire() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.current = ""
+}
+
+func (s *testAuthServer) client(creds ClientCredentials) *Client {
+	serverURL, _ := url.Parse(s.URL)
+	return NewClient(*serverURL, false, creds, ClientOptions{})
+}
+
+func TestClientSessionReauthenticates(t *testing.T) {
+	server := newTestAuthServer(t)
+	client := server.client(ClientCredentials{Username: "admin", Password: "secret"})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Templates(context.Background()); err != nil {
+			t.Fatalf("Templates: %s", err)
+		}
+	}
+	if server.logins != 1 {
+		t.Fatalf("expected 1 login, got %d", server.logins)
+	}
+
+	server.expire()
+	if _, err := client.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates after session expiry: %s", err)
+	}
+	if server.logins != 2 {
+		t.Fatalf("expected 2 logins, got %d", server.logins)
+	}
+}
+
+func TestClientSessionInvalidCredentials(t *testing.T) {
+	server := newTestAuthServer(t)
+	client := server.client(ClientCredentials{Username: "admin", Password: "wrong"})
+
+	if _, err := client.Templates(context.Background()); err == nil {
+		t.Fatalf("expected an error for invalid credentials")
+	}
+	if server.logins != 0 {
+		t.Fatalf("expected no successful login, got %d", server.logins)
+	}
+}
+
+func TestClientOAuth2RefreshesToken(t *testing.T) {
+	server := newTestAuthServer(t)
+	client := server.client(ClientCredentials{
+		ClientID:     "terraform",
+		ClientSecret: "secret",
+		TokenURL:     server.URL + DefaultTokenPath,
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Templates(context.Background()); err != nil {
+			t.Fatalf("Templates: %s", err)
+		}
+	}
+	if server.tokenFetches != 1 {
+		t.Fatalf("expected the token to be cached, got %d fetches", server.tokenFetches)
+	}
+
+	server.expire()
+	if _, err := client.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates after token revocation: %s", err)
+	}
+	if server.tokenFetches != 2 {
+		t.Fatalf("expected 2 token fetches, got %d", server.tokenFetches)
+	}
+}
+
+func TestClientOAuth2InvalidClient(t *testing.T) {
+	server := newTestAuthServer(t)
+	client := server.client(ClientCredentials{
+		ClientID:     "terraform",
+		ClientSecret: "wrong",
+	})
+
+	if _, err := client.Templates(context.Background()); err == nil {
+		t.Fatalf("expected an error for invalid client credentials")
+	}
+}
+
+func TestClientStaticToken(t *testing.T) {
+	server := newTestAuthServer(t)
+	server.mutex.Lock()
+	token := server.issue()
+	server.mutex.Unlock()
+
+	client := server.client(ClientCredentials{Token: token})
+	if _, err := client.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+
+	// Static tokens cannot be renewed: a revoked token is an error
+	server.expire()
+	if _, err := client.Templates(context.Background()); err == nil {
+		t.Fatalf("expected an error for a revoked static token")
+	}
+}
+
+func TestClientCredentialsValidate(t *testing.T) {
+	cases := []struct {
+		creds ClientCredentials
+		valid bool
+	}{
+		{ClientCredentials{Username: "admin", Password: "secret"}, true},
+		{ClientCredentials{Username: "admin"}, false},
+		{ClientCredentials{ClientID: "terraform", ClientSecret: "secret"}, true},
+		{ClientCredentials{AuthMethod: AuthMethodOAuth2, Username: "admin", Password: "secret"}, false},
+		{ClientCredentials{Token: "token"}, true},
+		{ClientCredentials{AuthMethod: "kerberos", Token: "token"}, false},
+	}
+	for idx, c := range cases {
+		err := c.creds.Validate()
+		if c.valid && err != nil {
+			t.Errorf("[%d] unexpected error: %s", idx, err)
+		} else if !c.valid && err == nil {
+			t.Errorf("[%d] expected an error", idx)
+		}
+	}
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		attempts[r.Method]++
+		attempt := attempts[r.Method]
+		mutex.Unlock()
+		if attempt < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{AuthMethod: AuthMethodBasic, Username: "admin", Password: "secret"},
+		ClientOptions{MaxRetries: 3, RetryMinWait: time.Millisecond},
+	)
+
+	if _, err := client.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+	if attempts[http.MethodGet] != 3 {
+		t.Fatalf("expected 3 GET attempts, got %d", attempts[http.MethodGet])
+	}
+
+	// POST is not idempotent and must not be retried by default
+	if _, err := client.CreateTemplate(context.Background(), &TemplateApiInputDTO{}); err == nil {
+		t.Fatalf("expected the 503 to be returned for a POST")
+	}
+	if attempts[http.MethodPost] != 1 {
+		t.Fatalf("expected 1 POST attempt, got %d", attempts[http.MethodPost])
+	}
+}
+
+func TestClientDoesNotRetryPermanentErrors(t *testing.T) {
+	var mutex sync.Mutex
+	tokenFetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		tokenFetches++
+		mutex.Unlock()
+		fmt.Fprint(w, `{"access_token":`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{ClientID: "terraform", ClientSecret: "secret", TokenURL: server.URL},
+		ClientOptions{MaxRetries: 3, RetryMinWait: time.Millisecond},
+	)
+
+	if _, err := client.Templates(context.Background()); err == nil {
+		t.Fatalf("expected an error for an undecodable token response")
+	}
+	if tokenFetches != 1 {
+		t.Fatalf("expected 1 token request, got %d", tokenFetches)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{&APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{&APIError{StatusCode: http.StatusBadRequest}, false},
+		{io.ErrUnexpectedEOF, true},
+		{&url.Error{Op: "Get", URL: "https://turbo", Err: io.EOF}, true},
+		{&url.Error{Op: "Get", URL: "https://turbo", Err: reset}, true},
+		{reset, true},
+		{&url.Error{Op: "Get", URL: "https://turbo", Err: &net.DNSError{IsTimeout: true}}, true},
+		{&url.Error{Op: "Get", URL: "https://turbo", Err: &net.DNSError{IsNotFound: true}}, false},
+		{&url.Error{Op: "Get", URL: "https://turbo", Err: x509.UnknownAuthorityError{}}, false},
+		{&url.Error{Op: "Get", URL: "turbo", Err: errors.New("unsupported protocol scheme")}, false},
+		{fmt.Errorf("Unable to obtain an access token: [%s]", "invalid_client"), false},
+		{&json.SyntaxError{}, false},
+	}
+	for idx, c := range cases {
+		if retryable := isRetryableError(c.err); retryable != c.retryable {
+			t.Errorf("[%d] %v: expected retryable [%t], got [%t]", idx, c.err, c.retryable, retryable)
+		}
+	}
+}
+
+// countingServer serves every request with the given handler and counts the
+// requests received.
+type countingServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests int
+}
+
+func newCountingServer(t *testing.T, handler http.HandlerFunc) *countingServer {
+	s := &countingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		s.requests++
+		s.mutex.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *countingServer) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+func (s *countingServer) client() *Client {
+	serverURL, _ := url.Parse(s.URL)
+	return NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{Token: "token"},
+		ClientOptions{MaxRetries: 3, RetryMinWait: time.Minute, RetryMaxWait: time.Minute},
+	)
+}
+
+func TestClientCanceledMidRequest(t *testing.T) {
+	received := make(chan struct{}, 1)
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := server.client().Templates(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected [%s], got [%v]", context.Canceled, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("returned [%s] after the cancellation", elapsed)
+	}
+	if server.count() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", server.count())
+	}
+}
+
+func TestClientCanceledDuringRetryBackoff(t *testing.T) {
+	responded := make(chan struct{}, 1)
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		responded <- struct{}{}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-responded
+		// give the client time to start waiting out its minute-long backoff
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := server.client().Templates(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected [%s], got [%v]", context.Canceled, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("returned [%s] after the cancellation", elapsed)
+	}
+	if server.count() != 1 {
+		t.Fatalf("expected 1 attempt, got %d", server.count())
+	}
+}
+
+func TestClientDeadlineExpiresWhilePolling(t *testing.T) {
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"uuid":"1","status":"IN_PROGRESS"}`)
+	})
+	client := server.client()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var err error
+	for err == nil {
+		_, err = client.ReadReservation(ctx, "1")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected [%s], got [%v]", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("returned [%s] after the deadline", elapsed)
+	}
+
+	// no request is sent, or retried, once the deadline has passed
+	polls := server.count()
+	time.Sleep(100 * time.Millisecond)
+	if server.count() != polls {
+		t.Fatalf("expected %d polls, got %d", polls, server.count())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		wait := backoff(time.Second, 8*time.Second, attempt)
+		ceiling := time.Second << uint(attempt)
+		if ceiling > 8*time.Second {
+			ceiling = 8 * time.Second
+		}
+		if wait < ceiling/2 || wait > ceiling {
+			t.Errorf("attempt %d: wait [%s] outside of [%s, %s]", attempt, wait, ceiling/2, ceiling)
+		}
+	}
+}
+
+func TestSendAndParseReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"type":"Exception","exception":"UnknownObjectException","message":"No template with uuid: 42"}`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{Token: "token"},
+		ClientOptions{},
+	)
+
+	_, err := client.ReadTemplate(context.Background(), "42")
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected an *APIError, got [%T] %v", err, err)
+	}
+	if apiErr.Method != http.MethodGet || apiErr.Response.Message != "No template with uuid: 42" {
+		t.Fatalf("unexpected APIError: %+v", apiErr)
+	}
+	if !IsNotFound(err) || IsConflict(err) || IsUnauthorized(err) {
+		t.Fatalf("misclassified error: %v", err)
+	}
+}
+
+func TestListAllFollowsCursor(t *testing.T) {
+	pages := map[string]struct {
+		body       string
+		nextCursor string
+	}{
+		"":   {`[{"uuid":"1"},{"uuid":"2"}]`, "c1"},
+		"c1": {`[{"uuid":"3"},{"uuid":"4"}]`, "c2"},
+		"c2": {`[{"uuid":"5"}]`, ""},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "2" {
+			t.Errorf("unexpected limit [%s]", r.URL.Query().Get("limit"))
+		}
+		page := pages[r.URL.Query().Get("cursor")]
+		if page.nextCursor != "" {
+			w.Header().Set(NextCursorHeader, page.nextCursor)
+		}
+		fmt.Fprint(w, page.body)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{Token: "token"},
+		ClientOptions{PageSize: 2},
+	)
+
+	templates, err := client.Templates(context.Background())
+	if err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+	if len(templates) != 5 || templates[4].UUID != "5" {
+		t.Fatalf("expected 5 templates across 3 pages, got %+v", templates)
+	}
+}
+
+func TestClientCapsConcurrentRequests(t *testing.T) {
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{Token: "token"},
+		ClientOptions{MaxConcurrentRequests: 2, MaxRequestsPerSecond: 1000, RequestBurst: 10},
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Templates(context.Background()); err != nil {
+				t.Errorf("Templates: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > 2 {
+		t.Fatalf("expected at most 2 requests in flight, got %d", maxInFlight)
+	}
+}
+
+func TestClientCachesAndCoalescesReads(t *testing.T) {
+	var mutex sync.Mutex
+	reads := 0
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		mutex.Lock()
+		reads++
+		mutex.Unlock()
+		<-release
+		fmt.Fprint(w, `[{"uuid":"1"}]`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{Token: "token"},
+		ClientOptions{CacheTTL: time.Minute},
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Templates(context.Background()); err != nil {
+				t.Errorf("Templates: %s", err)
+			}
+		}()
+	}
+	// give every reader time to join the in-flight request
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if _, err := client.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+	if reads != 1 {
+		t.Fatalf("expected 1 read served to every caller, got %d", reads)
+	}
+
+	if err := client.DeleteTemplate(context.Background(), "1"); err != nil {
+		t.Fatalf("DeleteTemplate: %s", err)
+	}
+	if _, err := client.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+	if reads != 2 {
+		t.Fatalf("expected the delete to invalidate the cached read, got %d reads", reads)
+	}
+}
+
+func TestClientCoalescedReadsHonorContexts(t *testing.T) {
+	var mutex sync.Mutex
+	reads := 0
+	received := make(chan struct{}, 10)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		reads++
+		mutex.Unlock()
+		received <- struct{}{}
+		select {
+		case <-release:
+			fmt.Fprint(w, `[{"uuid":"1"}]`)
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{Token: "token"},
+		ClientOptions{CacheTTL: time.Minute},
+	)
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := client.Templates(leaderCtx)
+		leaderErr <- err
+	}()
+	<-received
+
+	// a canceled waiter returns without waiting for the in-flight read
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	waiterErr := make(chan error, 1)
+	go func() {
+		_, err := client.Templates(waiterCtx)
+		waiterErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancelWaiter()
+	select {
+	case err := <-waiterErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected [%s], got [%v]", context.Canceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("canceled waiter still waiting for the in-flight read")
+	}
+
+	// a healthy waiter does not inherit the cancellation of the leader
+	healthyErr := make(chan error, 1)
+	go func() {
+		_, err := client.Templates(context.Background())
+		healthyErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the leader to fail with [%s], got [%v]", context.Canceled, err)
+	}
+	<-received
+	close(release)
+	if err := <-healthyErr; err != nil {
+		t.Fatalf("expected the healthy waiter to fetch the templates, got [%v]", err)
+	}
+	if reads != 2 {
+		t.Fatalf("expected the abandoned read to be sent again, got %d reads", reads)
+	}
+}
+
+func TestClientTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}))
+
+	serverURL, _ := url.Parse(server.URL)
+	untrusted := NewClient(*serverURL, false, ClientCredentials{Token: "token"}, ClientOptions{})
+	if _, err := untrusted.Templates(context.Background()); err == nil {
+		t.Fatalf("expected the self-signed certificate to be rejected")
+	}
+
+	// httptest certificates are issued for example.com
+	options := TLSOptions{CABundle: caPEM, MinVersion: "1.2", ServerName: "example.com"}
+	if err := options.Validate(); err != nil {
+		t.Fatalf("Validate: %s", err)
+	}
+	trusted := NewClient(*serverURL, false, ClientCredentials{Token: "token"}, ClientOptions{TLS: options})
+	if _, err := trusted.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+}
+
+func TestTLSOptionsValidate(t *testing.T) {
+	invalid := []TLSOptions{
+		{MinVersion: "1.4"},
+		{CABundle: "-----BEGIN CERTIFICATE-----\nnot a certificate\n-----END CERTIFICATE-----"},
+		{CABundle: "/does/not/exist.pem"},
+		{ClientCert: "/does/not/exist.pem"},
+	}
+	for _, options := range invalid {
+		if err := options.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", options)
+		}
+	}
+}
+
+func TestClientAPIBasePath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/turbo/api/v2/"+TemplatesPrefix {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(
+		*serverURL,
+		false,
+		ClientCredentials{Token: "token"},
+		ClientOptions{APIBasePath: "turbo/api/v2/"},
+	)
+	if _, err := client.Templates(context.Background()); err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+}
+
+func TestParseServerVersion(t *testing.T) {
+	cases := []struct {
+		dto      ProductVersionDTO
+		expected string
+		xl       bool
+	}{
+		{
+			dto:      ProductVersionDTO{VersionInfo: `Turbonomic Operations Manager 8.2.0 (Build "20210602130022000") "2021-06-07 14:33:48"`},
+			expected: "8.2.0 (build 20210602130022000)",
+			xl:       true,
+		},
+		{
+			dto:      ProductVersionDTO{VersionInfo: "Operations Manager 6.4.10 (Build 20200203)"},
+			expected: "6.4.10 (build 20200203)",
+		},
+		{
+			dto:      ProductVersionDTO{Version: "7.22", Build: "abc"},
+			expected: "7.22.0 (build abc)",
+			xl:       true,
+		},
+	}
+	for _, c := range cases {
+		version, err := parseServerVersion(&c.dto)
+		if err != nil {
+			t.Errorf("%+v: %s", c.dto, err)
+			continue
+		}
+		if version.String() != c.expected || version.IsXL() != c.xl {
+			t.Errorf("%+v: expected [%s] (xl: %t), got [%s] (xl: %t)",
+				c.dto, c.expected, c.xl, version.String(), version.IsXL())
+		}
+	}
+
+	if _, err := parseServerVersion(&ProductVersionDTO{VersionInfo: "unknown"}); err == nil {
+		t.Errorf("expected an error for a version without a number")
+	}
+}
+
+func TestClientRejectsUnsupportedFeatures(t *testing.T) {
+	posts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == APIURLPrefix+"/"+VersionsPrefix {
+			fmt.Fprint(w, `{"versionInfo":"Turbonomic Operations Manager 8.2.0 (Build \"20210602\")"}`)
+			return
+		}
+		posts++
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := NewClient(*serverURL, false, ClientCredentials{Token: "token"}, ClientOptions{})
+	if !client.Capabilities().ReservationDeployDateTime {
+		t.Fatalf("expected every capability before the version is detected")
+	}
+	if _, err := client.DetectVersion(context.Background()); err != nil {
+		t.Fatalf("DetectVersion: %s", err)
+	}
+
+	for _, rCreate := range []*ReservationCreate{
+		{DeployDateTime: "2019-01-01T00:00:00Z"},
+		{Parameters: []ReservationParameter{
+			{DeploymentParameters: ReservationDeploymentParameter{Priority: "HIGH"}},
+		}},
+		{Parameters: []ReservationParameter{
+			{PlacementParameters: ReservationPlacementParameter{GeographicRedundancy: true}},
+		}},
+	} {
+		if _, err := client.CreateReservation(context.Background(), rCreate, false); !IsUnsupported(err) {
+			t.Fatalf("%+v: expected an unsupported error, got %v", rCreate, err)
+		}
+	}
+	_, err := client.CreateTemplate(
+		context.Background(),
+		&TemplateApiInputDTO{ClassName: ClassNameContainer},
+	)
+	if !IsUnsupported(err) {
+		t.Fatalf("expected an unsupported error, got %v", err)
+	}
+	if posts != 0 {
+		t.Fatalf("expected unsupported requests not to be sent, got %d", posts)
+	}
+
+	_, err = client.CreateReservation(
+		context.Background(),
+		&ReservationCreate{Parameters: []ReservationParameter{
+			{DeploymentParameters: ReservationDeploymentParameter{HighAvailability: true}},
+		}},
+		false,
+	)
+	if err != nil {
+		t.Fatalf("expected a highly available reservation to be sent, got %v", err)
+	}
+	if posts != 1 {
+		t.Fatalf("expected 1 reservation to be sent, got %d", posts)
+	}
+}
+
+func TestClientProbeClassifiesFailures(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIURLPrefix+"/"+LoginPrefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "session"})
+	})
+	mux.HandleFunc(APIURLPrefix+"/"+VersionsPrefix, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"versionInfo":"Turbonomic Operations Manager 8.2.0"}`)
+	})
+	mux.HandleFunc("/html/"+VersionsPrefix, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html>login</html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(mux)
+	defer tlsServer.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	tlsServerURL, _ := url.Parse(tlsServer.URL)
+	unknownURL, _ := url.Parse("https://turbonomic.invalid")
+	password := func(password string) ClientCredentials {
+		return ClientCredentials{Username: "admin", Password: password}
+	}
+
+	cases := []struct {
+		name        string
+		server      url.URL
+		credentials ClientCredentials
+		options     ClientOptions
+		stage       string
+	}{
+		{"ok", *serverURL, password("secret"), ClientOptions{}, ""},
+		{"dns", *unknownURL, password("secret"), ClientOptions{}, ProbeStageDNS},
+		{"tls", *tlsServerURL, password("secret"), ClientOptions{}, ProbeStageTLS},
+		{"auth", *serverURL, password("wrong"), ClientOptions{}, ProbeStageAuthentication},
+		{"path", *serverURL, password("secret"), ClientOptions{APIBasePath: "/turbo/api/v2"}, ProbeStageAPIPath},
+		{"html", *serverURL, ClientCredentials{Token: "token"}, ClientOptions{APIBasePath: "/html"}, ProbeStageAPIPath},
+	}
+	for _, c := range cases {
+		client := NewClient(c.server, false, c.credentials, c.options)
+		_, err := client.Probe(context.Background())
+		if c.stage == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", c.name, err)
+			}
+			continue
+		}
+		probeErr, ok := err.(*ProbeError)
+		if !ok {
+			t.Errorf("%s: expected a ProbeError, got %v", c.name, err)
+			continue
+		}
+		if probeErr.Stage != c.stage {
+			t.Errorf("%s: expected the %s stage to fail, got %s: %s", c.name, c.stage, probeErr.Stage, probeErr)
+		}
+	}
+}
+
+func TestClientLogsRedactedJSON(t *testing.T) {
+	server := newTestAuthServer(t)
+	serverURL, _ := url.Parse(server.URL)
+	var output bytes.Buffer
+	options := ClientOptions{LogFormat: LogFormatJSON, LogOutput: &output, LogMaxBodySize: 32}
+
+	session := NewClient(*serverURL, false, ClientCredentials{Username: "admin", Password: "secret"}, options)
+	oauth2 := NewClient(*serverURL, false, ClientCredentials{
+		ClientID:     "terraform",
+		ClientSecret: "secret",
+		TokenURL:     server.URL + DefaultTokenPath,
+	}, options)
+	for _, client := range []*Client{session, oauth2} {
+		for i := 0; i < 2; i++ {
+			if _, err := client.Templates(context.Background()); err != nil {
+				t.Fatalf("Templates: %s", err)
+			}
+		}
+	}
+
+	for _, secret := range []string{"=secret", "credential-"} {
+		if strings.Contains(output.String(), secret) {
+			t.Errorf("log contains [%s]:\n%s", secret, output.String())
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	// one login, one token request and four template reads
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 log entries, got %d:\n%s", len(lines), output.String())
+	}
+	requestIDs := map[string]bool{}
+	for _, line := range lines {
+		var entry exchangeLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON log entry [%s]: %s", line, err)
+		}
+		if entry.RequestID == "" || entry.Method == "" || entry.Status != http.StatusOK {
+			t.Errorf("incomplete log entry [%s]", line)
+		}
+		requestIDs[entry.RequestID] = true
+		if strings.HasSuffix(entry.Endpoint, TemplatesPrefix) &&
+			!strings.HasSuffix(entry.Response, "[7 bytes truncated]") {
+			t.Errorf("expected the response body to be truncated, got [%s]", entry.Response)
+		}
+	}
+	if len(requestIDs) != len(lines) {
+		t.Errorf("expected unique request IDs, got %v", requestIDs)
+	}
+}
+
+func TestClientLogsWithoutBodies(t *testing.T) {
+	server := newTestAuthServer(t)
+	serverURL, _ := url.Parse(server.URL)
+	var output bytes.Buffer
+	options := ClientOptions{LogFormat: LogFormatJSON, LogOutput: &output}
+
+	client := NewClient(*serverURL, false, ClientCredentials{Username: "admin", Password: "secret"}, options)
+	templates, err := client.Templates(context.Background())
+	if err != nil {
+		t.Fatalf("Templates: %s", err)
+	}
+	if len(templates) != 1 {
+		t.Fatalf("expected the response body to reach the caller, got %+v", templates)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var entry exchangeLogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON log entry [%s]: %s", line, err)
+		}
+		if entry.Response != "" || entry.ResponseHeader != nil {
+			t.Errorf("expected no response body or headers, got [%s]", line)
+		}
+		if strings.HasSuffix(entry.Endpoint, TemplatesPrefix) &&
+			entry.RespLength != len(`[{"uuid":"1","displayName":"template"}]`) {
+			t.Errorf("expected the Content-Length to be logged, got [%s]", line)
+		}
+	}
+}
+
+func TestClientMiddlewares(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Correlation-ID") != "apply-1" {
+			t.Errorf("missing correlation header on [%s]", r.URL.Path)
+		}
+		if r.URL.Path == APIURLPrefix+"/"+TemplatesPrefix+"/_missing1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `[{"uuid":"_tpl1"}]`)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	var order []string
+	tracing := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) (*Response, error) {
+				order = append(order, name)
+				return next(req)
+			}
+		}
+	}
+	correlation := func(next Handler) Handler {
+		return func(req *http.Request) (*Response, error) {
+			req.Header.Set("X-Correlation-ID", "apply-1")
+			return next(req)
+		}
+	}
+	metrics := NewMetrics("")
+	client := NewClient(*serverURL, false, ClientCredentials{Token: "token"}, ClientOptions{
+		Middlewares: []Middleware{tracing("outer"), correlation, metrics.Middleware(), tracing("inner")},
+	})
+
+	for _, uuid := range []string{"_tpl1", "_tpl2", "_missing1"} {
+		client.ReadTemplate(context.Background(), uuid)
+	}
+	client.Templates(context.Background())
+
+	if strings.Join(order[:2], ",") != "outer,inner" {
+		t.Errorf("expected the middlewares to run outermost first, got %v", order)
+	}
+	snapshot := metrics.Snapshot()
+	counts := map[string][2]int{}
+	for _, stats := range snapshot {
+		counts[stats.Endpoint] = [2]int{stats.Requests, stats.Errors}
+	}
+	expected := map[string][2]int{
+		"GET /templates/{uuid}": {3, 1},
+		"GET /templates":        {1, 0},
+	}
+	if fmt.Sprint(counts) != fmt.Sprint(expected) {
+		t.Errorf("expected counters %v, got %v", expected, counts)
+	}
+	if !strings.Contains(metrics.Summary(), "GET /templates/{uuid}") {
+		t.Errorf("expected the endpoint in the summary:\n%s", metrics.Summary())
+	}
+}
+
+func TestStatApiDTORoundTrip(t *testing.T) {
+	raw := `{
+		"name": "Mem",
+		"units": "KB",
+		"numRelatedEntities": 3,
+		"value": 1536.75,
+		"capacity": {"avg": 2097152.5, "max": "Infinity", "min": 0.25, "total": "Infinity"},
+		"reserved": {"avg": "NaN", "total": "-Infinity"},
+		"values": {"avg": 1024.125, "max": 2048, "min": "512.5", "total": 1536.75},
+		"relatedEntity": {"uuid": "_pm1", "displayName": "host", "className": "PhysicalMachine"}
+	}`
+
+	var stat StatApiDTO
+	if err := json.Unmarshal([]byte(raw), &stat); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+	if stat.NumRelatedEntities != 3 || stat.Value != 1536.75 || stat.Capacity.Min != 0.25 {
+		t.Errorf("numeric fields not decoded: %+v", stat)
+	}
+	if !math.IsInf(float64(stat.Capacity.Max), 1) ||
+		!math.IsInf(float64(stat.Reserved.Total), -1) ||
+		!math.IsNaN(float64(stat.Reserved.Avg)) {
+		t.Errorf("special values not decoded: capacity %+v reserved %+v", *stat.Capacity, *stat.Reserved)
+	}
+	if stat.Values.Min != 512.5 {
+		t.Errorf("expected a number formatted as a string to be decoded, got [%v]", stat.Values.Min)
+	}
+
+	encoded, err := json.Marshal(stat)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	var original, roundTripped map[string]interface{}
+	json.Unmarshal([]byte(raw), &original)
+	json.Unmarshal(encoded, &roundTripped)
+	// numbers formatted as strings are encoded back as numbers
+	original["values"].(map[string]interface{})["min"] = 512.5
+	if !reflect.DeepEqual(original, roundTripped) {
+		t.Errorf("round trip mismatch:\n  original: %v\n  encoded:  %v", original, roundTripped)
+	}
+
+	// like any number, a zero value is omitted
+	if encoded, _ := json.Marshal(StatApiDTO{Name: "Mem", Value: 0}); string(encoded) != `{"name":"Mem"}` {
+		t.Errorf("expected the zero value to be omitted, got %s", encoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"value": "lots"}`), &stat); err == nil {
+		t.Errorf("expected an error for a non-numeric value")
+	}
+}
+
+func TestParseStatDate(t *testing.T) {
+	now := time.Date(2021, 6, 2, 13, 30, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"now":                  now,
+		"-7d":                  now.Add(-7 * 24 * time.Hour),
+		"+12h":                 now.Add(12 * time.Hour),
+		"-2w":                  now.Add(-14 * 24 * time.Hour),
+		"2021-05-01":           time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+		"2021-05-01T10:00:00Z": time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC),
+		"1622640600000":        now,
+	}
+	for value, expected := range cases {
+		parsed, err := ParseStatDate(value, now)
+		if err != nil {
+			t.Errorf("%s: %s", value, err)
+		} else if !parsed.Equal(expected) {
+			t.Errorf("%s: expected [%s], got [%s]", value, expected, parsed)
+		}
+	}
+	if FormatStatDate(now) != "1622640600000" {
+		t.Errorf("expected the date in milliseconds, got [%s]", FormatStatDate(now))
+	}
+	for _, value := range []string{"", "-7", "7d", "-1y", "yesterday"} {
+		if _, err := ParseStatDate(value, now); err == nil {
+			t.Errorf("expected an error for [%s]", value)
+		}
+	}
+}
+
+func TestRollupStatSnapshots(t *testing.T) {
+	cpu := func(avg, max, min StatFloat) StatApiDTO {
+		return StatApiDTO{
+			Name:     "CPU",
+			Units:    "MHz",
+			Values:   &StatValueApiDTO{Avg: avg, Max: max, Min: min, Total: avg},
+			Capacity: &StatValueApiDTO{Avg: 1000, Max: 1000, Min: 1000, Total: 1000},
+		}
+	}
+	snapshots := []StatSnapshotApiDTO{
+		{Date: "2021-06-02T10:00:00Z", Epoch: EpochHistorical, Statistics: []StatApiDTO{cpu(300, 900, 100)}},
+		{Date: "2021-06-01T10:00:00Z", Epoch: EpochHistorical, Statistics: []StatApiDTO{cpu(100, 200, 50)}},
+		{Date: "2021-06-01T22:00:00Z", Epoch: EpochHistorical, Statistics: []StatApiDTO{
+			cpu(200, 400, 10),
+			{Name: "Mem", Value: 512},
+		}},
+		{Date: "2021-06-03T10:00:00Z", Epoch: EpochProjected, Statistics: []StatApiDTO{cpu(500, 500, 500)}},
+	}
+
+	daily, err := RollupStatSnapshots(snapshots, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("RollupStatSnapshots: %s", err)
+	}
+	dates := []string{}
+	for _, snapshot := range daily {
+		dates = append(dates, snapshot.Epoch+" "+snapshot.Date)
+	}
+	expectedDates := []string{
+		"HISTORICAL 2021-06-01T00:00:00Z",
+		"HISTORICAL 2021-06-02T00:00:00Z",
+		"PROJECTED 2021-06-03T00:00:00Z",
+	}
+	if !reflect.DeepEqual(dates, expectedDates) {
+		t.Fatalf("expected snapshots %v, got %v", expectedDates, dates)
+	}
+	first := daily[0].Statistics
+	if len(first) != 2 || first[0].Name != "CPU" || first[1].Name != "Mem" {
+		t.Fatalf("expected the CPU and Mem stats, got %+v", first)
+	}
+	if *first[0].Values != (StatValueApiDTO{Avg: 150, Max: 400, Min: 10, Total: 150}) || first[0].Value != 150 {
+		t.Errorf("unexpected CPU rollup: value [%v] values %+v", first[0].Value, *first[0].Values)
+	}
+	if first[0].Capacity.Total != 1000 || first[1].Values.Avg != 512 || first[1].Capacity != nil {
+		t.Errorf("unexpected capacity or Mem rollup: %+v %+v", *first[0].Capacity, first[1])
+	}
+
+	all, err := RollupStatSnapshots(snapshots, 0)
+	if err != nil {
+		t.Fatalf("RollupStatSnapshots: %s", err)
+	}
+	if len(all) != 2 || all[0].Statistics[0].Values.Max != 900 || all[1].Epoch != EpochProjected {
+		t.Errorf("expected one historical and one projected snapshot, got %+v", all)
+	}
+
+	sorted, err := RollupStatSnapshots(snapshots, -1)
+	if err != nil {
+		t.Fatalf("RollupStatSnapshots: %s", err)
+	}
+	dates = []string{}
+	for _, snapshot := range sorted {
+		dates = append(dates, fmt.Sprintf("%s %v", snapshot.Date, snapshot.Statistics[0].Values.Avg))
+	}
+	expectedDates = []string{
+		"2021-06-01T10:00:00Z 100",
+		"2021-06-01T22:00:00Z 200",
+		"2021-06-02T10:00:00Z 300",
+		"2021-06-03T10:00:00Z 500",
+	}
+	if !reflect.DeepEqual(dates, expectedDates) {
+		t.Errorf("expected the snapshots sorted without aggregation %v, got %v", expectedDates, dates)
+	}
+}
@@ -2,14 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"math"
-
-	log "github.com/foo/terraform-provider-utils/log"
+	"strconv"
 )
 
 // Constants used to denote the various class types
 const (
 	ClassNameContainer       = "Container"
+	ClassNameDataCenter      = "DataCenter"
 	ClassNamePhysicalMachine = "PhysicalMachine"
 	ClassNameStorage         = "Storage"
 	ClassNameVirtualMachine  = "VirtualMachine"
@@ -45,86 +46,88 @@ type ResourceApiDTO struct {
 	Type     string       `json:"type,omitempty"`
 }
 
+// Special stat values, which Turbonomic sends as JSON strings
 const (
-	ValueInfinity = "Infinity"
+	ValueInfinity         = "Infinity"
+	ValueNegativeInfinity = "-Infinity"
+	ValueNaN              = "NaN"
 )
 
+// StatFloat - numeric stat value.  Turbonomic sends most values as JSON
+// numbers, but unbounded capacities as the string "Infinity", undefined
+// ratios as "NaN" and some values as numeric strings, ie: "512.5".
+// StatFloat decodes each of these forms.  It encodes infinities and NaN as
+// strings and every other value as a JSON number, so numeric strings are
+// not encoded back as strings.  As with any number, a zero StatFloat is
+// dropped from fields tagged `omitempty`.
+type StatFloat float64
+
+// MarshalJSON implements json.Marshaler.  Infinities and NaN are encoded as
+// strings, as sent by Turbonomic.
+func (f StatFloat) MarshalJSON() ([]byte, error) {
+	value := float64(f)
+	switch {
+	case math.IsInf(value, 1):
+		return json.Marshal(ValueInfinity)
+	case math.IsInf(value, -1):
+		return json.Marshal(ValueNegativeInfinity)
+	case math.IsNaN(value):
+		return json.Marshal(ValueNaN)
+	}
+	return json.Marshal(value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.  Accepts JSON numbers, the
+// special values as strings, and numbers formatted as strings.
+func (f *StatFloat) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var raw interface{}
+	if jsonDecErr := json.Unmarshal(b, &raw); jsonDecErr != nil {
+		return jsonDecErr
+	}
+	switch value := raw.(type) {
+	case float64:
+		*f = StatFloat(value)
+	case string:
+		switch value {
+		case ValueInfinity:
+			*f = StatFloat(math.Inf(1))
+		case ValueNegativeInfinity:
+			*f = StatFloat(math.Inf(-1))
+		case ValueNaN:
+			*f = StatFloat(math.NaN())
+		default:
+			parsed, parseErr := strconv.ParseFloat(value, 64)
+			if parseErr != nil {
+				return fmt.Errorf("Invalid stat value [%s]", value)
+			}
+			*f = StatFloat(parsed)
+		}
+	default:
+		return fmt.Errorf("Invalid stat value [%s]", b)
+	}
+	return nil
+}
+
 type StatApiDTO struct {
-	Capacity           StatValueApiDTO    `json:"capacity,omitempty"`
+	// Capacity, reserved and current values of the stat.  Nil when not
+	// reported.
+	Capacity           *StatValueApiDTO   `json:"capacity,omitempty"`
 	ClassName          string             `json:"className,omitempty"`
 	DisplayName        string             `json:"displayName,omitempty"`
 	Filters            []StatFilterApiDTO `json:"filters,omitempty"`
 	Links              []Link             `json:"links,omitempty"`
 	Name               string             `json:"name,omitempty"`
-	NumRelatedEntities int                `json:"numRelatedEntities,omitempty"`
-	RelatedEntity      BaseApiDTO         `json:"relatedEntity,omitempty"`
+	NumRelatedEntities StatFloat          `json:"numRelatedEntities,omitempty"`
+	RelatedEntity      *BaseApiDTO        `json:"relatedEntity,omitempty"`
 	RelatedEntityType  string             `json:"relatedEntityType,omitempty"`
-	Reserved           StatValueApiDTO    `json:"reserved,omitempty"`
+	Reserved           *StatValueApiDTO   `json:"reserved,omitempty"`
 	Units              string             `json:"units,omitempty"`
 	UUID               string             `json:"uuid,omitempty"`
-	Value              float64            `json:"value,omitempty"`
-	Values             StatValueApiDTO    `json:"values,omitempty"`
-}
-
-func (obj *StatApiDTO) UnmarshalJSON(b []byte) error {
-	log.Tracef("StatApiDTO#UnmarshalJSON")
-
-	var jsonDecErr error
-
-	// temporary struct to unmarshal embedded,complex types
-	var tmpJSON struct {
-		Capacity      StatValueApiDTO    `json:"capacity,omitempty"`
-		Filters       []StatFilterApiDTO `json:"filters,omitempty"`
-		Links         []Link             `json:"links,omitempty"`
-		RelatedEntity BaseApiDTO         `json:"relatedEntity,omitempty"`
-		Reserved      StatValueApiDTO    `json:"reserved,omitempty"`
-		Values        StatValueApiDTO    `json:"values,omitempty"`
-	}
-	jsonDecErr = json.Unmarshal(b, &tmpJSON)
-	if jsonDecErr != nil {
-		return jsonDecErr
-	}
-	obj.Capacity = tmpJSON.Capacity
-	obj.Filters = tmpJSON.Filters
-	obj.Links = tmpJSON.Links
-	obj.RelatedEntity = tmpJSON.RelatedEntity
-	obj.Reserved = tmpJSON.Reserved
-	obj.Values = tmpJSON.Values
-
-	// Unmarshal the rest of the primitive attributes into a map
-	var objMap map[string]interface{}
-	jsonDecErr = json.Unmarshal(b, &objMap)
-	if jsonDecErr != nil {
-		return jsonDecErr
-	}
-	obj.ClassName, _ = objMap["className"].(string)
-	obj.DisplayName, _ = objMap["displayName"].(string)
-	obj.Name, _ = objMap["name"].(string)
-	obj.NumRelatedEntities, _ = objMap["numRelatedEntities"].(int)
-	obj.RelatedEntityType, _ = objMap["relatedEntityType"].(string)
-	obj.Units, _ = objMap["units"].(string)
-	obj.UUID, _ = objMap["uuid"].(string)
-
-	// Unmarshal into temporary struct to handle API irregularities
-	var objJSON struct {
-		// Value is usually a float64, however in some instances it returns as
-		// the value "Infinity" as a string. Capture either option, and convert
-		// to float64
-		Value interface{} `json:"value,omitempty"`
-	}
-	jsonDecErr = json.Unmarshal(b, &objJSON)
-	if jsonDecErr != nil {
-		return jsonDecErr
-	}
-	if value, ok := objJSON.Value.(float64); ok {
-		obj.Value = value
-	} else if value, ok := objJSON.Value.(string); ok {
-		if value == ValueInfinity {
-			obj.Value = math.Inf(1)
-		}
-	}
-
-	return nil
+	Value              StatFloat          `json:"value,omitempty"`
+	Values             *StatValueApiDTO   `json:"values,omitempty"`
 }
 
 type StatFilterApiDTO struct {
@@ -133,8 +136,8 @@ type StatFilterApiDTO struct {
 }
 
 type StatValueApiDTO struct {
-	Avg   int `json:"avg,omitempty"`
-	Max   int `json:"max,omitempty"`
-	Min   int `json:"min,omitempty"`
-	Total int `json:"total,omitempty"`
+	Avg   StatFloat `json:"avg,omitempty"`
+	Max   StatFloat `json:"max,omitempty"`
+	Min   StatFloat `json:"min,omitempty"`
+	Total StatFloat `json:"total,omitempty"`
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -170,7 +171,7 @@ type DeploymentProfileTargetApiInputDTO struct {
 // DeploymentProfileApiInputDTO. It returns a reference to the
 // DeploymentProfileApiInputDTO that was returned, representing the created
 // deployment profile or an error if encountered.
-func (c *Client) CreateDeploymentProfile(obj *DeploymentProfileApiInputDTO) (*DeploymentProfileApiDTO, error) {
+func (c *Client) CreateDeploymentProfile(ctx context.Context, obj *DeploymentProfileApiInputDTO) (*DeploymentProfileApiDTO, error) {
 	log.Tracef("turbonomic/api/deployment_profiles.go#CreateDeploymentProfile")
 
 	reqEndpoint := fmt.Sprintf("/%s", DeploymentProfilesPrefix)
@@ -181,6 +182,7 @@ func (c *Client) CreateDeploymentProfile(obj *DeploymentProfileApiInputDTO) (*De
 	}
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodPost,
 		reqEndpoint,
 		bytes.NewBuffer(objJSONBytes),
@@ -200,12 +202,13 @@ func (c *Client) CreateDeploymentProfile(obj *DeploymentProfileApiInputDTO) (*De
 
 // ReadTemplate returns a DeploymentProfileApiDTO representing the deployment
 // profile identified by the supplied UUID or an error if encountered.
-func (c *Client) ReadDeploymentProfile(uuid string) (*DeploymentProfileApiDTO, error) {
+func (c *Client) ReadDeploymentProfile(ctx context.Context, uuid string) (*DeploymentProfileApiDTO, error) {
 	log.Tracef("turbonomic/api/deployment_profiles.go#ReadDeploymentProfile")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s", DeploymentProfilesPrefix, uuid)
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodGet,
 		reqEndpoint,
 		nil,
@@ -228,7 +231,7 @@ func (c *Client) ReadDeploymentProfile(uuid string) (*DeploymentProfileApiDTO, e
 // of the supplied DeploymentProfileApiInputDTO. This function returns a
 // reference to the deployment profile with the updated properties or an error
 // if encountered.
-func (c *Client) UpdateDeploymentProfile(uuid string, obj *DeploymentProfileApiInputDTO) (*DeploymentProfileApiDTO, error) {
+func (c *Client) UpdateDeploymentProfile(ctx context.Context, uuid string, obj *DeploymentProfileApiInputDTO) (*DeploymentProfileApiDTO, error) {
 	log.Tracef("turbonomic/api/deployment_profiles.go#UpdateDeploymentProfile")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s", DeploymentProfilesPrefix, uuid)
@@ -239,6 +242,7 @@ func (c *Client) UpdateDeploymentProfile(uuid string, obj *DeploymentProfileApiI
 	}
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodPut,
 		reqEndpoint,
 		bytes.NewBuffer(objJSONBytes),
@@ -258,12 +262,13 @@ func (c *Client) UpdateDeploymentProfile(uuid string, obj *DeploymentProfileApiI
 
 // DeleteDeploymentProfile deletes the Deployment Profile identitied by the
 // given UUID. It will return an error if encountered.
-func (c *Client) DeleteDeploymentProfile(uuid string) error {
+func (c *Client) DeleteDeploymentProfile(ctx context.Context, uuid string) error {
 	log.Tracef("turbonomic/api/deployment_profiles.go#DeleteDeploymentProfile")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s", DeploymentProfilesPrefix, uuid)
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodDelete,
 		reqEndpoint,
 		nil,
@@ -276,25 +281,32 @@ func (c *Client) DeleteDeploymentProfile(uuid string) error {
 }
 
 // DeploymentProfiles returns all Deployment Profile objects in Turbonomic or
-// an error if one encountered.
-func (c *Client) DeploymentProfiles() ([]DeploymentProfileApiDTO, error) {
+// an error if one encountered. All pages of the list are read.
+func (c *Client) DeploymentProfiles(ctx context.Context) ([]DeploymentProfileApiDTO, error) {
 	log.Tracef("turbonomic/api/deployment_profiles.go#DeploymentProfiles")
 
 	reqEndpoint := fmt.Sprintf("/%s", DeploymentProfilesPrefix)
 
-	req, reqErr := c.NewRequest(
-		http.MethodGet,
-		reqEndpoint,
-		nil,
-	)
-	if reqErr != nil {
-		return nil, reqErr
+	var profiles []DeploymentProfileApiDTO
+	listErr := c.ListAll(ctx, reqEndpoint, nil, &profiles)
+	if listErr != nil {
+		return nil, listErr
 	}
+	return profiles, nil
+}
+
+// SearchDeploymentProfiles returns the deployment profiles matching the
+// query or an error if one encountered.  The query's Types are set to the
+// deployment profile search type.
+func (c *Client) SearchDeploymentProfiles(ctx context.Context, query SearchQuery) ([]DeploymentProfileApiDTO, error) {
+	log.Tracef("turbonomic/api/deployment_profiles.go#SearchDeploymentProfiles")
+
+	query.Types = []string{SearchTypeDeploymentProfile}
 
 	var profiles []DeploymentProfileApiDTO
-	sendErr := c.SendAndParse(req, &profiles)
-	if sendErr != nil {
-		return nil, sendErr
+	searchErr := c.Search(ctx, query, &profiles)
+	if searchErr != nil {
+		return nil, searchErr
 	}
 	return profiles, nil
 }
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+const (
+	// EntitiesPrefix - API endpoint for reading service entities, ie: VMs,
+	// hosts and datastores
+	EntitiesPrefix = "entities"
+
+	// Epochs of a StatSnapshotApiDTO
+	EpochHistorical = "HISTORICAL"
+	EpochCurrent    = "CURRENT"
+	EpochProjected  = "PROJECTED"
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// TargetApiDTO - target (ie: a vCenter) discovering entities
+type TargetApiDTO struct {
+	// Category of the target, ie: "Hypervisor"
+	Category    string `json:"category,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	// Type of the target, ie: "vCenter"
+	Type string `json:"type,omitempty"`
+	UUID string `json:"uuid,omitempty"`
+}
+
+// ServiceEntityApiDTO - entity managed by Turbonomic, ie: a VM, a host or a
+// datastore
+type ServiceEntityApiDTO struct {
+	// Type of entity, ie: "VirtualMachine"
+	ClassName string `json:"className,omitempty"`
+	// Target the entity was first discovered by
+	DiscoveredBy *TargetApiDTO `json:"discoveredBy,omitempty"`
+	DisplayName  string        `json:"displayName,omitempty"`
+	// Environment of the entity, ie: "ONPREM" or "CLOUD"
+	EnvironmentType string `json:"environmentType,omitempty"`
+	Links           []Link `json:"links,omitempty"`
+	// Entities the entity consumes from, ie: the datacenter of a host
+	Providers []BaseApiDTO `json:"providers,omitempty"`
+	// Severity of the entity's pending actions, ie: "Normal" or "Critical"
+	Severity string `json:"severity,omitempty"`
+	// State of the entity, ie: "ACTIVE" or "SUSPEND"
+	State string `json:"state,omitempty"`
+	// Tags of the entity, keyed by name
+	Tags map[string][]string `json:"tags,omitempty"`
+	UUID string              `json:"uuid,omitempty"`
+	// Identifiers of the entity in each of the targets discovering it, keyed
+	// by the display name of the target
+	VendorIds map[string]string `json:"vendorIds,omitempty"`
+}
+
+// DiscoveringTargets returns the display names of the targets discovering
+// the entity, the target that first discovered it first.
+func (obj *ServiceEntityApiDTO) DiscoveringTargets() []string {
+	targets := []string{}
+	seen := map[string]bool{}
+	if obj.DiscoveredBy != nil && obj.DiscoveredBy.DisplayName != "" {
+		targets = append(targets, obj.DiscoveredBy.DisplayName)
+		seen[obj.DiscoveredBy.DisplayName] = true
+	}
+	others := []string{}
+	for target := range obj.VendorIds {
+		if !seen[target] {
+			others = append(others, target)
+		}
+	}
+	sort.Strings(others)
+	return append(targets, others...)
+}
+
+// ProviderOfClass returns the first provider of the entity of the given
+// class, ie: ClassNameDataCenter for the datacenter of a host.  The boolean
+// is false if the entity has no such provider.
+func (obj *ServiceEntityApiDTO) ProviderOfClass(className string) (BaseApiDTO, bool) {
+	for _, provider := range obj.Providers {
+		if provider.ClassName == className {
+			return provider, true
+		}
+	}
+	return BaseApiDTO{}, false
+}
+
+// StatSnapshotApiDTO - stats of an entity at a point in time
+type StatSnapshotApiDTO struct {
+	// Date of the snapshot, ie: "2021-06-02T13:00:22Z"
+	Date        string `json:"date,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	// Whether the stats are historical, current or projected.  One of the
+	// EpochXxx constants.
+	Epoch      string       `json:"epoch,omitempty"`
+	Statistics []StatApiDTO `json:"statistics,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Read Implementation
+// -----------------------------------------------------------------------------
+
+// ReadEntity returns a ServiceEntityApiDTO representing the entity
+// identified by the supplied UUID or an error if encountered. If the entity
+// does not exist, the error satisfies IsNotFound.
+func (c *Client) ReadEntity(ctx context.Context, uuid string) (*ServiceEntityApiDTO, error) {
+	log.Tracef("turbonomic/api/entities.go#ReadEntity")
+
+	reqEndpoint := fmt.Sprintf("/%s/%s", EntitiesPrefix, uuid)
+
+	req, reqErr := c.NewRequest(
+		ctx,
+		http.MethodGet,
+		reqEndpoint,
+		nil,
+	)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	var readObj ServiceEntityApiDTO
+	sendErr := c.SendAndParse(req, &readObj)
+	if sendErr != nil {
+		return nil, sendErr
+	}
+
+	return &readObj, nil
+}
+
+// CurrentEntityStats returns the current commodity stats of the entity
+// identified by the supplied UUID or an error if encountered.  Servers that
+// only report historical snapshots have their latest snapshot returned.
+func (c *Client) CurrentEntityStats(ctx context.Context, uuid string) ([]StatApiDTO, error) {
+	log.Tracef("turbonomic/api/entities.go#CurrentEntityStats")
+
+	reqEndpoint := fmt.Sprintf("/%s/%s/stats", EntitiesPrefix, uuid)
+
+	req, reqErr := c.NewRequest(
+		ctx,
+		http.MethodGet,
+		reqEndpoint,
+		nil,
+	)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	var snapshots []StatSnapshotApiDTO
+	sendErr := c.SendAndParse(req, &snapshots)
+	if sendErr != nil {
+		return nil, sendErr
+	}
+
+	var latest *StatSnapshotApiDTO
+	for idx := range snapshots {
+		if snapshots[idx].Epoch == EpochCurrent {
+			return snapshots[idx].Statistics, nil
+		}
+		if snapshots[idx].Epoch != EpochProjected {
+			latest = &snapshots[idx]
+		}
+	}
+	if latest == nil {
+		return []StatApiDTO{}, nil
+	}
+	return latest.Statistics, nil
+}
+
+// SearchEntities returns the entities matching the query or an error if one
+// encountered.  The query should set the Types of the entities, ie:
+// ClassNameVirtualMachine.  Search results may omit the tags and targets of
+// the entities: use ReadEntity for the complete entity.
+func (c *Client) SearchEntities(ctx context.Context, query SearchQuery) ([]ServiceEntityApiDTO, error) {
+	log.Tracef("turbonomic/api/entities.go#SearchEntities")
+
+	var entities []ServiceEntityApiDTO
+	searchErr := c.Search(ctx, query, &entities)
+	if searchErr != nil {
+		return nil, searchErr
+	}
+	return entities, nil
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// ErrorApiDTO - error body returned by Turbonomic for failed requests
+type ErrorApiDTO struct {
+	// Category of the error, ie: "Exception"
+	Type string `json:"type,omitempty"`
+	// Server side exception raised while handling the request, ie:
+	// "UnknownObjectException"
+	Exception string `json:"exception,omitempty"`
+	// Human readable description of the error
+	Message string `json:"message,omitempty"`
+}
+
+// APIError is returned by the client when Turbonomic responds with a non-2xx
+// status code.  Use the IsXxx helpers to inspect the kind of failure rather
+// than comparing status codes directly.
+type APIError struct {
+	// HTTP status code of the response
+	StatusCode int
+	// HTTP method of the request
+	Method string
+	// Full URL of the request
+	Endpoint string
+	// Error body parsed from the response.  Empty if the body was not a
+	// Turbonomic error DTO.
+	Response ErrorApiDTO
+	// Raw response body
+	Body []byte
+}
+
+// newAPIError builds an APIError from a failed request and the server's
+// response body.
+func newAPIError(req *http.Request, statusCode int, respBody []byte) *APIError {
+	apiErr := APIError{
+		StatusCode: statusCode,
+		Method:     req.Method,
+		Endpoint:   req.URL.String(),
+		Body:       respBody,
+	}
+	// Not every error response is a JSON error DTO (ie: proxies returning
+	// HTML).  Ignore decoding failures and keep the raw body.
+	json.Unmarshal(respBody, &apiErr.Response)
+	return &apiErr
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	message := e.Response.Message
+	if message == "" {
+		message = string(e.Body)
+	}
+	return fmt.Sprintf(
+		"HTTP Error:{\n"+
+			"  endpoint:   [%s]\n"+
+			"  method:     [%s]\n"+
+			"  statusCode: [%d]\n"+
+			"  type:       [%s]\n"+
+			"  exception:  [%s]\n"+
+			"  message:    [%s]\n"+
+			"}",
+		e.Endpoint,
+		e.Method,
+		e.StatusCode,
+		e.Response.Type,
+		e.Response.Exception,
+		message,
+	)
+}
+
+// -----------------------------------------------------------------------------
+// Error Classification
+// -----------------------------------------------------------------------------
+
+// IsNotFound reports whether err indicates that the requested object does not
+// exist in Turbonomic.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound ||
+		strings.Contains(apiErr.Response.Exception, "UnknownObjectException")
+}
+
+// IsConflict reports whether err indicates that the request conflicts with
+// the current state of the object (ie: a duplicate name).
+func IsConflict(err error) bool {
+	return hasStatusCode(err, http.StatusConflict)
+}
+
+// IsUnauthorized reports whether err indicates that the client's credentials
+// were rejected.
+func IsUnauthorized(err error) bool {
+	return hasStatusCode(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err indicates that the authenticated user lacks
+// the permissions for the request.
+func IsForbidden(err error) bool {
+	return hasStatusCode(err, http.StatusForbidden)
+}
+
+// IsBadRequest reports whether err indicates that Turbonomic rejected the
+// request as invalid (ie: a validation error).
+func IsBadRequest(err error) bool {
+	return hasStatusCode(err, http.StatusBadRequest)
+}
+
+// hasStatusCode reports whether err is an APIError with the given status code
+func hasStatusCode(err error, statusCode int) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == statusCode
+}
@@ -0,0 +1,68 @@
+package fake
+
+import (
+	"net/http"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+// entity - entity and its current commodity stats
+type entity struct {
+	api.ServiceEntityApiDTO
+	stats []api.StatApiDTO
+}
+
+// -----------------------------------------------------------------------------
+// Seeding
+// -----------------------------------------------------------------------------
+
+// AddEntity adds an entity with the given current commodity stats as if it
+// was discovered by Turbonomic and returns its UUID.  A UUID is generated if
+// the entity has none, and the state defaults to "ACTIVE".
+func (server *Server) AddEntity(obj api.ServiceEntityApiDTO, stats []api.StatApiDTO) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	obj.UUID = server.adopt(obj.UUID, "7421876")
+	if obj.State == "" {
+		obj.State = "ACTIVE"
+	}
+	server.entities[obj.UUID] = &entity{ServiceEntityApiDTO: obj, stats: stats}
+	return obj.UUID
+}
+
+// -----------------------------------------------------------------------------
+// Handlers
+// -----------------------------------------------------------------------------
+
+// serveEntities handles /entities/{uuid} and /entities/{uuid}/stats.  The
+// stats are reported as a single CURRENT snapshot.
+func (server *Server) serveEntities(w http.ResponseWriter, r *http.Request, segments []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(segments) == 0 || segments[0] == "" {
+		writeError(w, http.StatusNotFound, "NotFoundException", "No such endpoint")
+		return
+	}
+	obj, ok := server.entities[segments[0]]
+	if !ok {
+		writeUnknownObject(w, "entity", segments[0])
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Entities are read-only")
+		return
+	}
+
+	switch {
+	case len(segments) == 1:
+		writeJSON(w, http.StatusOK, obj.ServiceEntityApiDTO)
+	case len(segments) == 2 && segments[1] == "stats":
+		writeJSON(w, http.StatusOK, []api.StatSnapshotApiDTO{{
+			Epoch:      api.EpochCurrent,
+			Statistics: obj.stats,
+		}})
+	default:
+		writeError(w, http.StatusNotFound, "NotFoundException", "No such endpoint")
+	}
+}
@@ -0,0 +1,383 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+const (
+	// ComputeProvider - host workloads without anti-affinity are placed on
+	ComputeProvider = "fake-host-01"
+	// StorageProvider - datastore every placed workload is placed on
+	StorageProvider = "fake-datastore-01"
+)
+
+// Reservation statuses reported by the fake
+const (
+	StatusFuture             = "FUTURE"
+	StatusInProgress         = "IN_PROGRESS"
+	StatusPlacementSucceeded = "PLACEMENT_SUCCEEDED"
+	StatusPlacementFailed    = "PLACEMENT_FAILED"
+	StatusReserved           = "RESERVED"
+	StatusUnfulfilled        = "UNFULFILLED"
+)
+
+// reservation - a reservation and the number of responses it still reports
+// IN_PROGRESS in
+type reservation struct {
+	response       api.ReservationResponse
+	remainingPolls int
+	// Status reported once the placement completes
+	finalStatus string
+	// Placements assigned to the demand entities once placed, by index
+	placements []api.Placement
+}
+
+// -----------------------------------------------------------------------------
+// Configuration and Inspection
+// -----------------------------------------------------------------------------
+
+// SetReservationPolls sets the number of responses, including the create
+// response, a new reservation reports IN_PROGRESS in before it is placed.
+// Reservations created with apiCallBlock=true are placed immediately.
+func (server *Server) SetReservationPolls(polls int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.reservationPolls = polls
+}
+
+// FailPlacement makes the placement of the reservations of the given
+// template fail with PLACEMENT_FAILED
+func (server *Server) FailPlacement(templateUUID string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.unplaceable[templateUUID] = StatusPlacementFailed
+}
+
+// UnfulfillPlacement makes the placement of the reservations of the given
+// template end UNFULFILLED, as when Turbonomic waits for capacity to place
+// them
+func (server *Server) UnfulfillPlacement(templateUUID string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.unplaceable[templateUUID] = StatusUnfulfilled
+}
+
+// HostName returns the name of the idx-th host added by AddHosts.  Workloads
+// without anti-affinity are placed on HostName(0), ie: ComputeProvider.
+func HostName(idx int) string {
+	return fmt.Sprintf("fake-host-%02d", idx+1)
+}
+
+// hostUUID returns the UUID of the idx-th host
+func hostUUID(idx int) string {
+	return HostName(idx) + "-uuid"
+}
+
+// AddHosts adds host entities, hostsPerDatacenter in each of the given
+// number of datacenter entities.  Highly available workloads are placed on
+// consecutive hosts, geographically redundant workloads on the first host of
+// consecutive datacenters.  Placements requiring more hosts or datacenters
+// than added fail.
+func (server *Server) AddHosts(datacenters int, hostsPerDatacenter int) {
+	for dcIdx := 0; dcIdx < datacenters; dcIdx++ {
+		dc := api.BaseApiDTO{
+			ClassName:   api.ClassNameDataCenter,
+			DisplayName: fmt.Sprintf("fake-dc-%02d", dcIdx+1),
+		}
+		dc.UUID = server.AddEntity(api.ServiceEntityApiDTO{
+			ClassName:   dc.ClassName,
+			DisplayName: dc.DisplayName,
+		}, nil)
+		for idx := dcIdx * hostsPerDatacenter; idx < (dcIdx+1)*hostsPerDatacenter; idx++ {
+			server.AddEntity(api.ServiceEntityApiDTO{
+				ClassName:   api.ClassNamePhysicalMachine,
+				DisplayName: HostName(idx),
+				UUID:        hostUUID(idx),
+				Providers:   []api.BaseApiDTO{dc},
+			}, nil)
+		}
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.datacenters = datacenters
+	server.hostsPerDatacenter = hostsPerDatacenter
+}
+
+// Reservation returns a copy of the reservation with the given UUID as last
+// read by a client.  The boolean is false if there is no such reservation.
+func (server *Server) Reservation(uuid string) (api.ReservationResponse, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	res, ok := server.reservations[uuid]
+	if !ok {
+		return api.ReservationResponse{}, false
+	}
+	return res.response, true
+}
+
+// Reservations returns copies of every reservation as last read by a client,
+// in creation order
+func (server *Server) Reservations() []api.ReservationResponse {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	uuids := []string{}
+	for uuid := range server.reservations {
+		uuids = append(uuids, uuid)
+	}
+	reservations := []api.ReservationResponse{}
+	for _, uuid := range server.sortedUUIDs(uuids) {
+		reservations = append(reservations, server.reservations[uuid].response)
+	}
+	return reservations
+}
+
+// SetReservationStatus changes the status of a placed reservation, ie: to
+// UNFULFILLED as after a market change.  Reservations that are no longer
+// placed lose their placements.  Returns false if there is no such
+// reservation.
+func (server *Server) SetReservationStatus(uuid string, status string) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	res, ok := server.reservations[uuid]
+	if !ok {
+		return false
+	}
+	res.response.Status = status
+	if status != StatusReserved && status != StatusPlacementSucceeded {
+		for idx := range res.response.DemandEntities {
+			res.response.DemandEntities[idx].Placements = api.Placement{}
+		}
+	}
+	return true
+}
+
+// ExpireReservation removes a reservation as Turbonomic does once it
+// expires.  Returns false if there is no such reservation.
+func (server *Server) ExpireReservation(uuid string) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if _, ok := server.reservations[uuid]; !ok {
+		return false
+	}
+	delete(server.reservations, uuid)
+	return true
+}
+
+// -----------------------------------------------------------------------------
+// Handlers
+// -----------------------------------------------------------------------------
+
+// serveReservations handles /reservations and /reservations/{uuid}
+func (server *Server) serveReservations(w http.ResponseWriter, r *http.Request, segments []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(segments) == 0 || segments[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			uuids := []string{}
+			for uuid := range server.reservations {
+				uuids = append(uuids, uuid)
+			}
+			reservations := []interface{}{}
+			for _, uuid := range server.sortedUUIDs(uuids) {
+				reservations = append(reservations, server.reservations[uuid].response)
+			}
+			writePage(w, r, reservations)
+		case http.MethodPost:
+			server.createReservation(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Unsupported method")
+		}
+		return
+	}
+
+	uuid := segments[0]
+	res, ok := server.reservations[uuid]
+	if !ok {
+		writeUnknownObject(w, "reservation", uuid)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		res.poll()
+		writeJSON(w, http.StatusOK, res.response)
+	case http.MethodDelete:
+		delete(server.reservations, uuid)
+		writeJSON(w, http.StatusOK, true)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Unsupported method")
+	}
+}
+
+// createReservation creates a reservation from the ReservationCreate in the
+// request body.  The caller must hold the mutex.
+func (server *Server) createReservation(w http.ResponseWriter, r *http.Request) {
+	var input api.ReservationCreate
+	if jsonDecErr := json.NewDecoder(r.Body).Decode(&input); jsonDecErr != nil {
+		writeError(w, http.StatusBadRequest, "InvalidOperationException", jsonDecErr.Error())
+		return
+	}
+	if input.DemandName == "" || len(input.Parameters) == 0 {
+		writeError(w, http.StatusBadRequest, "InvalidOperationException", "demandName and parameters are required")
+		return
+	}
+
+	res := &reservation{
+		response: api.ReservationResponse{
+			UUID:            server.newUUID("_res"),
+			DisplayName:     input.DemandName,
+			Status:          StatusInProgress,
+			ReserveDateTime: input.ReserveDateTime,
+			ExpireDateTime:  input.ExpireDateTime,
+			DeployDateTIme:  input.DeployDateTime,
+		},
+		remainingPolls: server.reservationPolls,
+		finalStatus:    StatusPlacementSucceeded,
+	}
+	if input.Action == "RESERVATION" {
+		res.finalStatus = StatusReserved
+	}
+
+	for _, param := range input.Parameters {
+		placement := param.PlacementParameters
+		template, known := server.templates[placement.TemplateID]
+		if !known {
+			res.finalStatus = StatusPlacementFailed
+		} else if status, ok := server.unplaceable[placement.TemplateID]; ok {
+			res.finalStatus = status
+		}
+		count := placement.Count
+		if count < 1 {
+			count = 1
+		}
+		res.response.Count += count
+
+		for idx := 0; idx < count; idx++ {
+			host, placeable := server.placementHost(param, idx)
+			if !placeable {
+				res.finalStatus = StatusPlacementFailed
+			}
+			entity := api.DemandEntity{
+				UUID:        server.newUUID("_dem"),
+				DisplayName: input.DemandName,
+				Template:    api.Identifier{UUID: placement.TemplateID},
+			}
+			if idx < len(placement.EntityNames) {
+				entity.DisplayName = placement.EntityNames[idx]
+			}
+			if known {
+				entity.Template.DisplayName = template.DisplayName
+				entity.Template.ClassName = template.ClassName
+			}
+			if profileID := param.DeploymentParameters.DeploymentProfileID; profileID != "" {
+				entity.DeploymentProfile = api.Identifier{UUID: profileID, ClassName: "ServiceCatalogItem"}
+				if profile, ok := server.deploymentProfiles[profileID]; ok {
+					entity.DeploymentProfile.DisplayName = profile.DisplayName
+				}
+			}
+			res.response.DemandEntities = append(res.response.DemandEntities, entity)
+			res.placements = append(res.placements, newPlacement(template, host))
+		}
+	}
+
+	if r.URL.Query().Get("apiCallBlock") == "true" {
+		res.remainingPolls = 0
+	}
+	// reservations starting in the future are not placed, and report no
+	// demand entities, until then
+	if reserveTime, parseErr := time.Parse(time.RFC3339, input.ReserveDateTime); parseErr == nil && reserveTime.After(time.Now()) {
+		res.response.Status = StatusFuture
+		res.response.DemandEntities = nil
+	}
+	res.poll()
+	server.reservations[res.response.UUID] = res
+	writeJSON(w, http.StatusOK, res.response)
+}
+
+// placementHost returns the index of the host the idx-th workload of the
+// parameter is placed on.  The boolean is false if there are not enough
+// hosts or datacenters to satisfy the anti-affinity of the parameter.  The
+// caller must hold the mutex.
+func (server *Server) placementHost(param api.ReservationParameter, idx int) (int, bool) {
+	switch {
+	case param.PlacementParameters.GeographicRedundancy:
+		return idx * server.hostsPerDatacenter, idx < server.datacenters
+	case param.DeploymentParameters.HighAvailability:
+		return idx, idx < server.datacenters*server.hostsPerDatacenter
+	default:
+		return 0, true
+	}
+}
+
+// poll records a response about the reservation, completing its placement
+// once the configured number of responses reported IN_PROGRESS
+func (res *reservation) poll() {
+	if res.response.Status != StatusInProgress {
+		return
+	}
+	if res.remainingPolls > 0 {
+		res.remainingPolls--
+		return
+	}
+
+	res.response.Status = res.finalStatus
+	if res.finalStatus != StatusPlacementSucceeded && res.finalStatus != StatusReserved {
+		return
+	}
+	for idx := range res.response.DemandEntities {
+		res.response.DemandEntities[idx].Placements = res.placements[idx]
+	}
+	if res.finalStatus == StatusReserved {
+		res.response.ReserveCount = res.response.Count
+	}
+}
+
+// newPlacement returns the placement of an instance of the template: its
+// compute resources on the given host and each of its storage resources on
+// StorageProvider, with the template's stats.  Unknown templates and
+// templates without storage resources are placed on a single disk.
+func newPlacement(template *api.TemplateApiDTO, host int) api.Placement {
+	placement := api.Placement{
+		ComputeResources: []api.ComputeResource{{
+			Provider: api.Identifier{
+				UUID:        hostUUID(host),
+				DisplayName: HostName(host),
+				ClassName:   api.ClassNamePhysicalMachine,
+			},
+		}},
+	}
+	if template == nil {
+		template = &api.TemplateApiDTO{}
+	}
+	for _, res := range template.ComputeResources {
+		placement.ComputeResources[0].Stats = append(placement.ComputeResources[0].Stats, res.Stats...)
+	}
+
+	storageResources := template.StorageResources
+	if len(storageResources) == 0 {
+		storageResources = []api.ResourceApiDTO{{Type: api.ResourceTypeDisk}}
+	}
+	for _, res := range storageResources {
+		diskType := res.Type
+		if diskType == "" {
+			diskType = api.ResourceTypeDisk
+		}
+		placement.StorageResources = append(placement.StorageResources, api.StorageResource{
+			Provider: api.Identifier{
+				UUID:        "fake-datastore-01-uuid",
+				DisplayName: StorageProvider,
+				ClassName:   api.ClassNameStorage,
+			},
+			Stats: res.Stats,
+			Type:  diskType,
+		})
+	}
+	return placement
+}
@@ -0,0 +1,209 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+// nameFilterTypes - criteria filter types on the display names, keyed by the
+// search type they apply to
+var nameFilterTypes = map[string]string{
+	api.ClassNameContainer + "Profile":       "containerTemplatesByName",
+	api.ClassNamePhysicalMachine + "Profile": "pmTemplatesByName",
+	api.ClassNameStorage + "Profile":         "storageTemplatesByName",
+	api.ClassNameVirtualMachine + "Profile":  "vmTemplatesByName",
+	api.SearchTypeDeploymentProfile:          "deploymentProfilesByName",
+}
+
+// nameMatcher reports whether a display name matches
+type nameMatcher func(name string) bool
+
+// -----------------------------------------------------------------------------
+// Handlers
+// -----------------------------------------------------------------------------
+
+// serveSearch handles /search and /search/criteria.  Templates, deployment
+// profiles and entities can be searched by type and name, and templates and
+// deployment profiles by the name criteria listed by /search/criteria.  Scopes, states and environment types are
+// ignored.  Results are summaries: use the object's endpoint for the
+// complete object.
+func (server *Server) serveSearch(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) == 1 && segments[0] == "criteria" && r.Method == http.MethodGet {
+		writePage(w, r, searchCriteria(searchTypes(r)))
+		return
+	}
+	if len(segments) > 0 && segments[0] != "" {
+		writeError(w, http.StatusNotFound, "NotFoundException", "No such endpoint")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Unsupported method")
+		return
+	}
+
+	matchers := []nameMatcher{}
+	if q := r.URL.Query().Get("q"); q != "" {
+		// Turbonomic matches the name regex case insensitively
+		pattern, compileErr := regexp.Compile("(?i)" + q)
+		if compileErr != nil {
+			writeError(w, http.StatusBadRequest, "InvalidOperationException", compileErr.Error())
+			return
+		}
+		matchers = append(matchers, pattern.MatchString)
+	}
+	if r.Method == http.MethodPost {
+		criteriaMatcher, criteriaErr := criteriaNameMatcher(r)
+		if criteriaErr != "" {
+			writeError(w, http.StatusBadRequest, "InvalidOperationException", criteriaErr)
+			return
+		}
+		matchers = append(matchers, criteriaMatcher)
+	}
+
+	types := map[string]bool{}
+	for _, searchType := range searchTypes(r) {
+		types[searchType] = true
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	objs := map[string]api.BaseApiDTO{}
+	for uuid, template := range server.templates {
+		objs[uuid] = api.BaseApiDTO{UUID: uuid, DisplayName: template.DisplayName, ClassName: template.ClassName}
+	}
+	for uuid, profile := range server.deploymentProfiles {
+		objs[uuid] = api.BaseApiDTO{UUID: uuid, DisplayName: profile.DisplayName, ClassName: profile.ClassName}
+	}
+	for uuid, obj := range server.entities {
+		objs[uuid] = api.BaseApiDTO{UUID: uuid, DisplayName: obj.DisplayName, ClassName: obj.ClassName}
+	}
+	uuids := []string{}
+	for uuid, obj := range objs {
+		if len(types) > 0 && !types[obj.ClassName] {
+			continue
+		}
+		matched := true
+		for _, matcher := range matchers {
+			matched = matched && matcher(obj.DisplayName)
+		}
+		if matched {
+			uuids = append(uuids, uuid)
+		}
+	}
+	results := []interface{}{}
+	for _, uuid := range server.sortedUUIDs(uuids) {
+		results = append(results, objs[uuid])
+	}
+	writePage(w, r, results)
+}
+
+// searchTypes returns the types of the objects searched by the request
+func searchTypes(r *http.Request) []string {
+	types := []string{}
+	for _, searchType := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if searchType != "" {
+			types = append(types, searchType)
+		}
+	}
+	return types
+}
+
+// searchCriteria returns the name criteria of the given search types, or of
+// every type if none is given
+func searchCriteria(types []string) []interface{} {
+	if len(types) == 0 {
+		for searchType := range nameFilterTypes {
+			types = append(types, searchType)
+		}
+		sort.Strings(types)
+	}
+	criteria := []interface{}{}
+	for _, searchType := range types {
+		filterType, ok := nameFilterTypes[searchType]
+		if !ok {
+			continue
+		}
+		criteria = append(criteria, api.SearchCriterionApiDTO{
+			ElementType: searchType,
+			FilterType:  filterType,
+			ExpTypes: []string{
+				api.ExpTypeEquals,
+				api.ExpTypeNotEquals,
+				api.ExpTypeRegexEquals,
+				api.ExpTypeRegexNotEquals,
+			},
+			IsNameFilter: true,
+		})
+	}
+	return criteria
+}
+
+// criteriaNameMatcher returns the matcher combining the name criteria in the
+// request body, or the message of the error to respond with
+func criteriaNameMatcher(r *http.Request) (nameMatcher, string) {
+	var input struct {
+		CriteriaList    []api.CriteriaList `json:"criteriaList"`
+		LogicalOperator string             `json:"logicalOperator"`
+	}
+	if jsonDecErr := json.NewDecoder(r.Body).Decode(&input); jsonDecErr != nil {
+		return nil, jsonDecErr.Error()
+	}
+
+	known := map[string]bool{}
+	for _, filterType := range nameFilterTypes {
+		known[filterType] = true
+	}
+	matchers := []nameMatcher{}
+	for _, criterion := range input.CriteriaList {
+		if !known[criterion.FilterType] {
+			return nil, "Unknown filter type: " + criterion.FilterType
+		}
+		expVal := criterion.ExpVal
+		switch criterion.ExpType {
+		case api.ExpTypeEquals, api.ExpTypeNotEquals:
+			expVal = "^" + regexp.QuoteMeta(expVal) + "$"
+		case api.ExpTypeRegexEquals, api.ExpTypeRegexNotEquals:
+		default:
+			return nil, "Unknown expression type: " + criterion.ExpType
+		}
+		if !criterion.CaseSensitive {
+			expVal = "(?i)" + expVal
+		}
+		pattern, compileErr := regexp.Compile(expVal)
+		if compileErr != nil {
+			return nil, compileErr.Error()
+		}
+		negated := criterion.ExpType == api.ExpTypeNotEquals || criterion.ExpType == api.ExpTypeRegexNotEquals
+		matchers = append(matchers, func(name string) bool {
+			return pattern.MatchString(name) != negated
+		})
+	}
+
+	switch input.LogicalOperator {
+	case "", api.LogicalOperatorAnd:
+		return func(name string) bool {
+			for _, matcher := range matchers {
+				if !matcher(name) {
+					return false
+				}
+			}
+			return true
+		}, ""
+	case api.LogicalOperatorOr:
+		return func(name string) bool {
+			for _, matcher := range matchers {
+				if matcher(name) {
+					return true
+				}
+			}
+			return len(matchers) == 0
+		}, ""
+	}
+	return nil, "Unknown logical operator: " + input.LogicalOperator
+}
@@ -0,0 +1,404 @@
+// Package fake provides an in-process fake of the Turbonomic REST API for
+// unit and acceptance tests.  The fake keeps its objects in memory and
+// implements the endpoints used by the provider: templates, deployment
+// profiles, markets, market policies, reservations, entities and their stats
+// and the search of these, plus the login and version endpoints used when
+// the provider is configured.
+//
+// Typical usage:
+//
+//   server := fake.NewServer()
+//   defer server.Close()
+//   server.AddMarket(api.TurboMarket{DisplayName: "Market"})
+//   client := api.NewClient(server.ServerURL(), false, api.ClientCredentials{
+//     Username: fake.Username,
+//     Password: fake.Password,
+//   }, api.ClientOptions{})
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+const (
+	// Username and Password - credentials accepted by the login endpoint
+	Username = "administrator"
+	Password = "a"
+	// Token - bearer token accepted by every endpoint
+	Token = "fake-token"
+	// Version - product version reported by /admin/versions
+	Version = "Turbonomic Operations Manager 8.2.0 (Build \"20210602130022000\")"
+	// sessionCookie - name of the session cookie set by the login endpoint
+	sessionCookie = "JSESSIONID"
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// Server is a fake Turbonomic API server.  It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mutex sync.Mutex
+	// Session IDs issued by the login endpoint
+	sessions map[string]bool
+	// Objects, keyed by UUID.  Lists are returned in creation order.
+	templates          map[string]*api.TemplateApiDTO
+	deploymentProfiles map[string]*api.DeploymentProfileApiDTO
+	markets            map[string]*api.TurboMarket
+	policies           map[string][]api.TurboMarketPolicy
+	reservations       map[string]*reservation
+	entities           map[string]*entity
+	// Historical and projected stat snapshots, keyed by scope UUID
+	statSnapshots map[string][]api.StatSnapshotApiDTO
+	// Creation order of each object, used to sort lists
+	order map[string]int
+	// Counter used to generate UUIDs
+	nextID int
+	// Number of responses a reservation reports IN_PROGRESS in before it is
+	// placed
+	reservationPolls int
+	// Templates that cannot be placed, by the status reservations of these
+	// templates end in
+	unplaceable map[string]string
+	// Hosts added by AddHosts: the number of datacenters and of hosts in
+	// each of them
+	datacenters        int
+	hostsPerDatacenter int
+	// Version information reported by /admin/versions
+	versionInfo string
+}
+
+// NewServer starts a fake Turbonomic API server.  The server must be closed
+// with Close.
+func NewServer() *Server {
+	server := &Server{
+		sessions:           map[string]bool{},
+		templates:          map[string]*api.TemplateApiDTO{},
+		deploymentProfiles: map[string]*api.DeploymentProfileApiDTO{},
+		markets:            map[string]*api.TurboMarket{},
+		policies:           map[string][]api.TurboMarketPolicy{},
+		reservations:       map[string]*reservation{},
+		entities:           map[string]*entity{},
+		statSnapshots:      map[string][]api.StatSnapshotApiDTO{},
+		order:              map[string]int{},
+		reservationPolls:   1,
+		unplaceable:        map[string]string{},
+		versionInfo:        Version,
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// SetVersion sets the version information reported by /admin/versions, ie:
+// "Turbonomic Operations Manager 6.4.10 (Build \"20210602\")" to emulate
+// Turbonomic classic
+func (server *Server) SetVersion(versionInfo string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.versionInfo = versionInfo
+}
+
+// ServerURL returns the URL of the server, as expected by api.NewClient
+func (server *Server) ServerURL() url.URL {
+	serverURL, _ := url.Parse(server.URL)
+	return *serverURL
+}
+
+// newUUID returns a new object UUID and records its creation order.  The
+// caller must hold the mutex.
+func (server *Server) newUUID(prefix string) string {
+	server.nextID++
+	uuid := fmt.Sprintf("%s%d", prefix, server.nextID)
+	server.order[uuid] = server.nextID
+	return uuid
+}
+
+// adopt records the creation order of an object with a caller supplied
+// UUID, or generates one.  The caller must hold the mutex.
+func (server *Server) adopt(uuid string, prefix string) string {
+	if uuid == "" {
+		return server.newUUID(prefix)
+	}
+	server.nextID++
+	server.order[uuid] = server.nextID
+	return uuid
+}
+
+// sortedUUIDs returns the UUIDs of the given objects in creation order.  The
+// caller must hold the mutex.
+func (server *Server) sortedUUIDs(uuids []string) []string {
+	sort.Slice(uuids, func(i, j int) bool {
+		return server.order[uuids[i]] < server.order[uuids[j]]
+	})
+	return uuids
+}
+
+// -----------------------------------------------------------------------------
+// Seeding
+// -----------------------------------------------------------------------------
+
+// AddDeploymentProfile adds a deployment profile and returns its UUID.  A
+// UUID is generated if the profile has none.
+func (server *Server) AddDeploymentProfile(profile api.DeploymentProfileApiDTO) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	profile.UUID = server.adopt(profile.UUID, "_dp")
+	if profile.ClassName == "" {
+		profile.ClassName = "ServiceCatalogItem"
+	}
+	server.deploymentProfiles[profile.UUID] = &profile
+	return profile.UUID
+}
+
+// AddMarket adds a market and returns its UUID.  A UUID is generated if the
+// market has none.
+func (server *Server) AddMarket(market api.TurboMarket) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	market.UUID = server.adopt(market.UUID, "_mkt")
+	if market.ClassName == "" {
+		market.ClassName = "Market"
+	}
+	if market.State == "" {
+		market.State = "SUCCEEDED"
+	}
+	server.markets[market.UUID] = &market
+	return market.UUID
+}
+
+// AddMarketPolicy adds a policy to the market with the given UUID and
+// returns the policy's UUID.  A UUID is generated if the policy has none.
+func (server *Server) AddMarketPolicy(marketUUID string, policy api.TurboMarketPolicy) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	policy.UUID = server.adopt(policy.UUID, "_pol")
+	server.policies[marketUUID] = append(server.policies[marketUUID], policy)
+	return policy.UUID
+}
+
+// -----------------------------------------------------------------------------
+// Routing
+// -----------------------------------------------------------------------------
+
+// serveHTTP authenticates and routes a request to the handler of its
+// collection
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, api.APIURLPrefix+"/") {
+		writeError(w, http.StatusNotFound, "NotFoundException", "No such endpoint")
+		return
+	}
+	// ie: "/api/v2/markets/_mkt1/policies" => ["markets", "_mkt1", "policies"]
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, api.APIURLPrefix), "/"), "/")
+
+	if segments[0] == api.LoginPrefix {
+		server.login(w, r)
+		return
+	}
+	if !server.authenticated(r) {
+		writeError(w, http.StatusUnauthorized, "UnauthorizedException", "Authentication required")
+		return
+	}
+
+	switch segments[0] {
+	case "admin":
+		if len(segments) == 2 && segments[1] == "versions" && r.Method == http.MethodGet {
+			server.mutex.Lock()
+			versionInfo := server.versionInfo
+			server.mutex.Unlock()
+			writeJSON(w, http.StatusOK, api.ProductVersionDTO{VersionInfo: versionInfo, MarketVersion: 2})
+			return
+		}
+	case api.TemplatesPrefix:
+		server.serveTemplates(w, r, segments[1:])
+		return
+	case api.DeploymentProfilesPrefix:
+		server.serveDeploymentProfiles(w, r, segments[1:])
+		return
+	case api.MarketsPrefix:
+		server.serveMarkets(w, r, segments[1:])
+		return
+	case api.ReservationsPrefix:
+		server.serveReservations(w, r, segments[1:])
+		return
+	case api.EntitiesPrefix:
+		server.serveEntities(w, r, segments[1:])
+		return
+	case api.StatsPrefix:
+		server.serveStats(w, r, segments[1:])
+		return
+	case api.SearchPrefix:
+		server.serveSearch(w, r, segments[1:])
+		return
+	}
+	writeError(w, http.StatusNotFound, "NotFoundException", "No such endpoint")
+}
+
+// login establishes a session for valid credentials
+func (server *Server) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Use POST to log in")
+		return
+	}
+	if r.FormValue("username") != Username || r.FormValue("password") != Password {
+		writeError(w, http.StatusUnauthorized, "UnauthorizedException", "Invalid username or password")
+		return
+	}
+	server.mutex.Lock()
+	session := server.newUUID("session")
+	server.sessions[session] = true
+	server.mutex.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/"})
+	writeJSON(w, http.StatusOK, map[string]string{"username": Username, "roleName": "ADMINISTRATOR"})
+}
+
+// authenticated reports whether the request carries a session cookie issued
+// by login or the fake bearer token
+func (server *Server) authenticated(r *http.Request) bool {
+	if r.Header.Get("Authorization") == "Bearer "+Token {
+		return true
+	}
+	cookie, cookieErr := r.Cookie(sessionCookie)
+	if cookieErr != nil {
+		return false
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.sessions[cookie.Value]
+}
+
+// -----------------------------------------------------------------------------
+// Deployment Profiles and Markets
+// -----------------------------------------------------------------------------
+
+// serveDeploymentProfiles handles /deploymentprofiles and
+// /deploymentprofiles/{uuid}
+func (server *Server) serveDeploymentProfiles(w http.ResponseWriter, r *http.Request, segments []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(segments) == 0 || segments[0] == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Deployment profiles are read-only")
+			return
+		}
+		uuids := []string{}
+		for uuid := range server.deploymentProfiles {
+			uuids = append(uuids, uuid)
+		}
+		profiles := []interface{}{}
+		for _, uuid := range server.sortedUUIDs(uuids) {
+			profiles = append(profiles, server.deploymentProfiles[uuid])
+		}
+		writePage(w, r, profiles)
+		return
+	}
+
+	profile, ok := server.deploymentProfiles[segments[0]]
+	if !ok || r.Method != http.MethodGet {
+		writeUnknownObject(w, "deployment profile", segments[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// serveMarkets handles /markets and /markets/{uuid}/policies
+func (server *Server) serveMarkets(w http.ResponseWriter, r *http.Request, segments []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Markets are read-only")
+		return
+	}
+
+	if len(segments) == 0 || segments[0] == "" {
+		uuids := []string{}
+		for uuid := range server.markets {
+			uuids = append(uuids, uuid)
+		}
+		markets := []interface{}{}
+		for _, uuid := range server.sortedUUIDs(uuids) {
+			markets = append(markets, server.markets[uuid])
+		}
+		writePage(w, r, markets)
+		return
+	}
+
+	if _, ok := server.markets[segments[0]]; !ok {
+		writeUnknownObject(w, "market", segments[0])
+		return
+	}
+	if len(segments) == 2 && segments[1] == "policies" {
+		policies := []interface{}{}
+		for _, policy := range server.policies[segments[0]] {
+			policies = append(policies, policy)
+		}
+		writePage(w, r, policies)
+		return
+	}
+	writeError(w, http.StatusNotFound, "NotFoundException", "No such endpoint")
+}
+
+// -----------------------------------------------------------------------------
+// Responses
+// -----------------------------------------------------------------------------
+
+// writePage writes the page of objs selected by the cursor and limit query
+// parameters, and the cursor of the next page in the X-Next-Cursor header.
+// The cursor is the offset of the page.
+func writePage(w http.ResponseWriter, r *http.Request, objs []interface{}) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+	limit, limitErr := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limitErr != nil || limit <= 0 {
+		limit = len(objs)
+	}
+	if offset > len(objs) {
+		offset = len(objs)
+	}
+	end := offset + limit
+	if end > len(objs) {
+		end = len(objs)
+	}
+	if end < len(objs) {
+		w.Header().Set(api.NextCursorHeader, strconv.Itoa(end))
+	}
+	writeJSON(w, http.StatusOK, objs[offset:end])
+}
+
+// writeJSON writes obj as the JSON response body
+func writeJSON(w http.ResponseWriter, statusCode int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(obj)
+}
+
+// writeError writes a Turbonomic error response
+func writeError(w http.ResponseWriter, statusCode int, exception string, message string) {
+	writeJSON(w, statusCode, api.ErrorApiDTO{
+		Type:      "Error",
+		Exception: exception,
+		Message:   message,
+	})
+}
+
+// writeUnknownObject writes the error response for an unknown UUID
+func writeUnknownObject(w http.ResponseWriter, kind string, uuid string) {
+	writeError(
+		w,
+		http.StatusNotFound,
+		"UnknownObjectException",
+		fmt.Sprintf("Could not find %s with uuid: %s", kind, uuid),
+	)
+}
@@ -0,0 +1,173 @@
+package fake
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+func newTestClient(server *Server) *api.Client {
+	return api.NewClient(server.ServerURL(), false, api.ClientCredentials{
+		Username: Username,
+		Password: Password,
+	}, api.ClientOptions{})
+}
+
+func TestTemplateDefaultStats(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := newTestClient(server)
+
+	created, err := client.CreateTemplate(context.Background(), &api.TemplateApiInputDTO{
+		ClassName:   api.ClassNameVirtualMachine,
+		DisplayName: "tpl",
+		ComputeResources: []api.ResourceApiDTO{{
+			Stats: []api.StatApiDTO{{Name: "numOfCpu", Value: 4}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CreateTemplate: %s", err)
+	}
+	if created.ClassName != "VirtualMachineProfile" {
+		t.Errorf("expected class [VirtualMachineProfile], got [%s]", created.ClassName)
+	}
+
+	stats := map[string]float64{}
+	for _, resource := range append(created.ComputeResources, created.StorageResources...) {
+		for _, stat := range resource.Stats {
+			stats[stat.Name] = float64(stat.Value)
+		}
+	}
+	expected := map[string]float64{"numOfCpu": 4, "cpuSpeed": 1000, "memorySize": 1024, "diskSize": 10}
+	for name, value := range expected {
+		if stats[name] != value {
+			t.Errorf("expected %s [%v], got [%v]", name, value, stats[name])
+		}
+	}
+}
+
+func TestReservationTransitions(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.SetReservationPolls(2)
+	client := newTestClient(server)
+	templateID := server.AddTemplate(api.TemplateApiDTO{DisplayName: "tpl"})
+	failingID := server.AddTemplate(api.TemplateApiDTO{DisplayName: "too big"})
+	server.FailPlacement(failingID)
+	unfulfilledID := server.AddTemplate(api.TemplateApiDTO{DisplayName: "no capacity"})
+	server.UnfulfillPlacement(unfulfilledID)
+
+	cases := []struct {
+		templateID string
+		expected   []string
+	}{
+		{templateID, []string{StatusInProgress, StatusInProgress, StatusPlacementSucceeded}},
+		{failingID, []string{StatusInProgress, StatusInProgress, StatusPlacementFailed}},
+		{unfulfilledID, []string{StatusInProgress, StatusInProgress, StatusUnfulfilled}},
+	}
+	for _, c := range cases {
+		res, err := client.CreateReservation(context.Background(), &api.ReservationCreate{
+			Action:     "PLACEMENT",
+			DemandName: "vm",
+			Parameters: []api.ReservationParameter{{
+				PlacementParameters: api.ReservationPlacementParameter{TemplateID: c.templateID, Count: 1},
+			}},
+		}, false)
+		if err != nil {
+			t.Fatalf("CreateReservation: %s", err)
+		}
+		statuses := []string{res.Status}
+		for len(statuses) < len(c.expected) {
+			res, err = client.ReadReservation(context.Background(), res.UUID)
+			if err != nil {
+				t.Fatalf("ReadReservation: %s", err)
+			}
+			statuses = append(statuses, res.Status)
+		}
+		for idx, status := range c.expected {
+			if statuses[idx] != status {
+				t.Fatalf("%s: expected statuses %v, got %v", c.templateID, c.expected, statuses)
+			}
+		}
+		if status := statuses[len(statuses)-1]; status == StatusPlacementSucceeded &&
+			res.DemandEntities[0].Placements.ComputeResources[0].Provider.DisplayName != ComputeProvider {
+			t.Errorf("expected a placement on [%s], got %+v", ComputeProvider, res.DemandEntities[0].Placements)
+		}
+
+		if err := client.DeleteReservation(context.Background(), res.UUID); err != nil {
+			t.Fatalf("DeleteReservation: %s", err)
+		}
+		if _, err := client.ReadReservation(context.Background(), res.UUID); !api.IsNotFound(err) {
+			t.Fatalf("expected a not found error after delete, got %v", err)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := api.NewClient(server.ServerURL(), false, api.ClientCredentials{
+		Username: Username,
+		Password: Password,
+	}, api.ClientOptions{PageSize: 1})
+	webID := server.AddTemplate(api.TemplateApiDTO{DisplayName: "web"})
+	webCopyID := server.AddTemplate(api.TemplateApiDTO{DisplayName: "WEB"})
+	dbID := server.AddTemplate(api.TemplateApiDTO{DisplayName: "db"})
+	profileID := server.AddDeploymentProfile(api.DeploymentProfileApiDTO{DisplayName: "web"})
+
+	cases := []struct {
+		name     string
+		query    api.SearchQuery
+		expected []string
+	}{
+		{"name", api.SearchQuery{NameRegex: api.ExactNameRegex("web")}, []string{webID, webCopyID}},
+		{"case sensitive criteria", api.SearchQuery{
+			Criteria: []api.CriteriaList{{
+				FilterType:    "vmTemplatesByName",
+				ExpType:       api.ExpTypeEquals,
+				ExpVal:        "web",
+				CaseSensitive: true,
+			}},
+		}, []string{webID}},
+		{"criteria or", api.SearchQuery{
+			Criteria: []api.CriteriaList{
+				{FilterType: "vmTemplatesByName", ExpType: api.ExpTypeRegexEquals, ExpVal: "^d"},
+				{FilterType: "vmTemplatesByName", ExpType: api.ExpTypeEquals, ExpVal: "web", CaseSensitive: true},
+			},
+			LogicalOperator: api.LogicalOperatorOr,
+		}, []string{webID, dbID}},
+	}
+	for _, c := range cases {
+		templates, err := client.SearchTemplates(context.Background(), c.query)
+		if err != nil {
+			t.Fatalf("%s: SearchTemplates: %s", c.name, err)
+		}
+		uuids := []string{}
+		for _, template := range templates {
+			uuids = append(uuids, template.UUID)
+		}
+		if strings.Join(uuids, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("%s: expected templates %v, got %v", c.name, c.expected, uuids)
+		}
+	}
+
+	profiles, err := client.SearchDeploymentProfiles(context.Background(), api.SearchQuery{
+		NameRegex: api.ExactNameRegex("web"),
+	})
+	if err != nil {
+		t.Fatalf("SearchDeploymentProfiles: %s", err)
+	}
+	if len(profiles) != 1 || profiles[0].UUID != profileID {
+		t.Errorf("expected deployment profile [%s], got %+v", profileID, profiles)
+	}
+
+	criteria, err := client.SearchCriteria(context.Background(), api.SearchTypeDeploymentProfile)
+	if err != nil {
+		t.Fatalf("SearchCriteria: %s", err)
+	}
+	if len(criteria) != 1 || criteria[0].FilterType != "deploymentProfilesByName" {
+		t.Errorf("expected the deployment profile name criterion, got %+v", criteria)
+	}
+}
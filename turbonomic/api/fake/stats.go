@@ -0,0 +1,95 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+// -----------------------------------------------------------------------------
+// Seeding
+// -----------------------------------------------------------------------------
+
+// AddStatSnapshots adds historical or projected stat snapshots to the scope
+// (entity, group or market) with the given UUID.  The snapshots must be
+// dated.
+func (server *Server) AddStatSnapshots(uuid string, snapshots ...api.StatSnapshotApiDTO) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.statSnapshots[uuid] = append(server.statSnapshots[uuid], snapshots...)
+}
+
+// -----------------------------------------------------------------------------
+// Handlers
+// -----------------------------------------------------------------------------
+
+// serveStats handles POST /stats/{uuid}.  Periods return the snapshots dated
+// within the period, and requests without a period the current stats of
+// the entity.  Only the requested stats are returned.
+func (server *Server) serveStats(w http.ResponseWriter, r *http.Request, segments []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(segments) != 1 || segments[0] == "" || r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, "NotFoundException", "No such endpoint")
+		return
+	}
+	uuid := segments[0]
+	obj, isEntity := server.entities[uuid]
+	snapshots, hasSnapshots := server.statSnapshots[uuid]
+	if !isEntity && !hasSnapshots {
+		writeUnknownObject(w, "scope", uuid)
+		return
+	}
+
+	var period api.StatPeriodApiInputDTO
+	if jsonDecErr := json.NewDecoder(r.Body).Decode(&period); jsonDecErr != nil {
+		writeError(w, http.StatusBadRequest, "InvalidOperationException", jsonDecErr.Error())
+		return
+	}
+	names := map[string]bool{}
+	for _, stat := range period.Statistics {
+		names[stat.Name] = true
+	}
+
+	if period.StartDate == "" && period.EndDate == "" {
+		current := api.StatSnapshotApiDTO{Epoch: api.EpochCurrent}
+		if isEntity {
+			current.Statistics = filterStats(obj.stats, names)
+		}
+		writeJSON(w, http.StatusOK, []api.StatSnapshotApiDTO{current})
+		return
+	}
+
+	now := time.Now()
+	start, startErr := api.ParseStatDate(period.StartDate, now)
+	end, endErr := api.ParseStatDate(period.EndDate, now)
+	if startErr != nil || endErr != nil {
+		writeError(w, http.StatusBadRequest, "InvalidOperationException", "Invalid period")
+		return
+	}
+	results := []api.StatSnapshotApiDTO{}
+	for _, snapshot := range snapshots {
+		date, _ := api.ParseStatDate(snapshot.Date, now)
+		if date.Before(start) || date.After(end) {
+			continue
+		}
+		snapshot.Statistics = filterStats(snapshot.Statistics, names)
+		results = append(results, snapshot)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// filterStats returns the stats with the given names, or every stat if no
+// name is given
+func filterStats(stats []api.StatApiDTO, names map[string]bool) []api.StatApiDTO {
+	filtered := []api.StatApiDTO{}
+	for _, stat := range stats {
+		if len(names) == 0 || names[stat.Name] {
+			filtered = append(filtered, stat)
+		}
+	}
+	return filtered
+}
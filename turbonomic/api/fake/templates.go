@@ -0,0 +1,182 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+// -----------------------------------------------------------------------------
+// Server-side Defaults
+// -----------------------------------------------------------------------------
+
+// defaultStats - stats Turbonomic adds to the templates of a class when they
+// are not provided, keyed by class and then by resource category
+var defaultStats = map[string]map[string][]api.StatApiDTO{
+	api.ClassNameVirtualMachine: {
+		"compute": {
+			{Name: "numOfCpu", Value: 1},
+			{Name: "cpuSpeed", Units: "MHz", Value: 1000},
+			{Name: "memorySize", Units: "MB", Value: 1024},
+		},
+		"storage": {
+			{Name: "diskSize", Units: "GB", Value: 10},
+		},
+	},
+}
+
+// applyDefaultStats adds the default stats of the template's class missing
+// from the template
+func applyDefaultStats(template *api.TemplateApiDTO, className string) {
+	defaults, ok := defaultStats[className]
+	if !ok {
+		return
+	}
+	template.ComputeResources = withDefaultStats(template.ComputeResources, defaults["compute"], "")
+	template.StorageResources = withDefaultStats(template.StorageResources, defaults["storage"], api.ResourceTypeDisk)
+}
+
+// withDefaultStats returns the resources with the defaults missing from their
+// stats added to the first resource
+func withDefaultStats(resources []api.ResourceApiDTO, defaults []api.StatApiDTO, resourceType string) []api.ResourceApiDTO {
+	if len(defaults) == 0 {
+		return resources
+	}
+	if len(resources) == 0 {
+		resources = []api.ResourceApiDTO{{Type: resourceType}}
+	}
+	present := map[string]bool{}
+	for _, resource := range resources {
+		for _, stat := range resource.Stats {
+			present[stat.Name] = true
+		}
+	}
+	for _, stat := range defaults {
+		if !present[stat.Name] {
+			resources[0].Stats = append(resources[0].Stats, stat)
+		}
+	}
+	return resources
+}
+
+// -----------------------------------------------------------------------------
+// Seeding and Inspection
+// -----------------------------------------------------------------------------
+
+// AddTemplate adds a template as if it was discovered by Turbonomic and
+// returns its UUID.  A UUID is generated if the template has none, and the
+// class defaults to "VirtualMachineProfile".  Default stats are not applied.
+func (server *Server) AddTemplate(template api.TemplateApiDTO) string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	template.UUID = server.adopt(template.UUID, "_tpl")
+	if template.ClassName == "" {
+		template.ClassName = api.ClassNameVirtualMachine + "Profile"
+	}
+	server.templates[template.UUID] = &template
+	return template.UUID
+}
+
+// Template returns a copy of the template with the given UUID.  The boolean
+// is false if there is no such template.
+func (server *Server) Template(uuid string) (api.TemplateApiDTO, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	template, ok := server.templates[uuid]
+	if !ok {
+		return api.TemplateApiDTO{}, false
+	}
+	return *template, true
+}
+
+// -----------------------------------------------------------------------------
+// Handlers
+// -----------------------------------------------------------------------------
+
+// serveTemplates handles /templates and /templates/{uuid}
+func (server *Server) serveTemplates(w http.ResponseWriter, r *http.Request, segments []string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if len(segments) == 0 || segments[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			uuids := []string{}
+			for uuid := range server.templates {
+				uuids = append(uuids, uuid)
+			}
+			templates := []interface{}{}
+			for _, uuid := range server.sortedUUIDs(uuids) {
+				templates = append(templates, server.templates[uuid])
+			}
+			writePage(w, r, templates)
+		case http.MethodPost:
+			server.saveTemplate(w, r, server.newUUID("_tpl"))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Unsupported method")
+		}
+		return
+	}
+
+	uuid := segments[0]
+	template, ok := server.templates[uuid]
+	if !ok {
+		writeUnknownObject(w, "template", uuid)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		// Turbonomic returns the template in a list
+		writeJSON(w, http.StatusOK, []api.TemplateApiDTO{*template})
+	case http.MethodPut:
+		server.saveTemplate(w, r, uuid)
+	case http.MethodDelete:
+		delete(server.templates, uuid)
+		writeJSON(w, http.StatusOK, true)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowedException", "Unsupported method")
+	}
+}
+
+// saveTemplate creates or replaces the template with the given UUID from the
+// TemplateApiInputDTO in the request body.  The caller must hold the mutex.
+func (server *Server) saveTemplate(w http.ResponseWriter, r *http.Request, uuid string) {
+	var input api.TemplateApiInputDTO
+	if jsonDecErr := json.NewDecoder(r.Body).Decode(&input); jsonDecErr != nil {
+		writeError(w, http.StatusBadRequest, "InvalidOperationException", jsonDecErr.Error())
+		return
+	}
+	if input.DisplayName == "" {
+		writeError(w, http.StatusBadRequest, "InvalidOperationException", "Template name is required")
+		return
+	}
+
+	// the input and output DTOs share most of their schema
+	inputBytes, _ := json.Marshal(input)
+	template := api.TemplateApiDTO{}
+	json.Unmarshal(inputBytes, &template)
+
+	className := strings.TrimSuffix(input.ClassName, "Profile")
+	if className == "" {
+		className = api.ClassNameVirtualMachine
+	}
+	template.UUID = uuid
+	template.ClassName = className + "Profile"
+	if input.DeploymentProfileId != "" {
+		profile, ok := server.deploymentProfiles[input.DeploymentProfileId]
+		if !ok {
+			writeUnknownObject(w, "deployment profile", input.DeploymentProfileId)
+			return
+		}
+		template.DeploymentProfile = *profile
+	}
+	for idx := range template.StorageResources {
+		template.StorageResources[idx].Type = api.ResourceTypeDisk
+	}
+	applyDefaultStats(&template, className)
+
+	server.templates[uuid] = &template
+	writeJSON(w, http.StatusOK, template)
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+const (
+	// LogFormatText - HTTP exchanges are logged as multi-line DEBUG messages
+	// through the provider log
+	LogFormatText = "text"
+	// LogFormatJSON - HTTP exchanges are logged as JSON objects, one per line,
+	// to ClientOptions.LogOutput
+	LogFormatJSON = "json"
+	// redacted - placeholder logged in place of secrets
+	redacted = "REDACTED"
+)
+
+var (
+	// redactedHeaders - headers carrying credentials
+	redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+	// secretFieldPattern - names of form fields, query parameters and JSON
+	// keys holding secrets, ie: "password", "client_secret", "access_token"
+	secretFieldPattern = regexp.MustCompile(`(?i)(password|secret|token)`)
+	// secretJSONPattern - string values of JSON keys holding secrets
+	secretJSONPattern = regexp.MustCompile(`(?i)("[^"]*(?:password|secret|token)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// LogFormats returns the supported log formats
+func LogFormats() []string {
+	return []string{LogFormatText, LogFormatJSON}
+}
+
+// -----------------------------------------------------------------------------
+// Redaction
+// -----------------------------------------------------------------------------
+
+// redactHeader returns a copy of the header with the values of the headers
+// carrying credentials replaced
+func redactHeader(header http.Header) http.Header {
+	copied := http.Header{}
+	for key, values := range header {
+		copied[key] = append([]string(nil), values...)
+	}
+	for _, key := range redactedHeaders {
+		if _, ok := copied[key]; ok {
+			copied.Set(key, redacted)
+		}
+	}
+	return copied
+}
+
+// redactURL returns the path and query of the URL with the values of the
+// query parameters holding secrets replaced
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	for key := range query {
+		if secretFieldPattern.MatchString(key) {
+			query.Set(key, redacted)
+		}
+	}
+	if len(query) == 0 {
+		return u.Path
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+// redactBody replaces the secrets in a form-encoded or JSON body
+func redactBody(contentType string, body []byte) string {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		form, parseErr := url.ParseQuery(string(body))
+		if parseErr != nil {
+			return redacted
+		}
+		for key := range form {
+			if secretFieldPattern.MatchString(key) {
+				form.Set(key, redacted)
+			}
+		}
+		return form.Encode()
+	}
+	return secretJSONPattern.ReplaceAllString(string(body), `$1"`+redacted+`"`)
+}
+
+// truncateBody caps the body to maxSize bytes.  A maxSize of zero omits the
+// body.
+func truncateBody(body string, maxSize int) string {
+	if maxSize <= 0 || body == "" {
+		return ""
+	}
+	if len(body) <= maxSize {
+		return body
+	}
+	return fmt.Sprintf("%s... [%d bytes truncated]", body[:maxSize], len(body)-maxSize)
+}
+
+// -----------------------------------------------------------------------------
+// Logging Transport
+// -----------------------------------------------------------------------------
+
+// exchangeLogEntry - log entry describing an HTTP exchange with the server
+type exchangeLogEntry struct {
+	Time       string  `json:"time"`
+	Level      string  `json:"level"`
+	RequestID  string  `json:"request_id"`
+	Method     string  `json:"method"`
+	Endpoint   string  `json:"endpoint"`
+	Status     int     `json:"status,omitempty"`
+	LatencyMS  float64 `json:"latency_ms"`
+	Error      string  `json:"error,omitempty"`
+	RespLength int     `json:"response_length"`
+	// Headers and bodies are only logged when bodies are enabled
+	RequestHeader  http.Header `json:"request_headers,omitempty"`
+	ResponseHeader http.Header `json:"response_headers,omitempty"`
+	Request        string      `json:"request_body,omitempty"`
+	Response       string      `json:"response_body,omitempty"`
+}
+
+// loggingTransport is an http.RoundTripper logging every round trip made
+// by the client's HTTP client, including logins and token requests, with
+// credentials redacted and bodies capped to maxBodySize bytes.
+type loggingTransport struct {
+	format      string
+	output      io.Writer
+	maxBodySize int
+	// Random prefix of the request IDs, distinguishing the clients of
+	// concurrent provider processes logging to the same file
+	idPrefix string
+	// Number of round trips made, used to generate request IDs
+	counter uint64
+	// Serializes the writes to output
+	mutex sync.Mutex
+	// Transport performing the actual round trip
+	next http.RoundTripper
+}
+
+// newLoggingTransport returns a loggingTransport configured by the client
+// options
+func newLoggingTransport(options ClientOptions, next http.RoundTripper) *loggingTransport {
+	transport := &loggingTransport{
+		format:      options.LogFormat,
+		output:      options.LogOutput,
+		maxBodySize: options.LogMaxBodySize,
+		next:        next,
+	}
+	if transport.format == "" {
+		transport.format = LogFormatText
+	}
+	if transport.output == nil {
+		transport.output = os.Stderr
+	}
+	prefix := make([]byte, 4)
+	rand.Read(prefix)
+	transport.idPrefix = hex.EncodeToString(prefix)
+	return transport
+}
+
+// RoundTrip implements http.RoundTripper.  When bodies are logged, the
+// response body is read to be logged and handed to the caller from memory.
+// Otherwise it is left to the caller and the logged length is the response's
+// Content-Length, -1 if unknown.
+func (transport *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := exchangeLogEntry{
+		RequestID: fmt.Sprintf("%s-%06d", transport.idPrefix, atomic.AddUint64(&transport.counter, 1)),
+		Method:    req.Method,
+		Endpoint:  redactURL(req.URL),
+	}
+	if transport.maxBodySize > 0 && req.Body != nil && req.Body != http.NoBody {
+		reqBody, readErr := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+		entry.Request = truncateBody(redactBody(req.Header.Get("Content-Type"), reqBody), transport.maxBodySize)
+	}
+	if transport.maxBodySize > 0 {
+		entry.RequestHeader = redactHeader(req.Header)
+	}
+
+	start := time.Now()
+	resp, respErr := transport.next.RoundTrip(req)
+	if respErr == nil {
+		entry.Status = resp.StatusCode
+		entry.RespLength = int(resp.ContentLength)
+		if transport.maxBodySize > 0 {
+			respBody, readErr := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+			respErr = readErr
+			entry.RespLength = len(respBody)
+			entry.Response = truncateBody(redactBody(resp.Header.Get("Content-Type"), respBody), transport.maxBodySize)
+			entry.ResponseHeader = redactHeader(resp.Header)
+		}
+	}
+	entry.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if respErr != nil {
+		entry.Error = respErr.Error()
+	}
+
+	transport.log(entry)
+	if respErr != nil {
+		return nil, respErr
+	}
+	return resp, nil
+}
+
+// log writes the entry in the configured format
+func (transport *loggingTransport) log(entry exchangeLogEntry) {
+	if transport.format != LogFormatJSON {
+		log.Debugf(
+			"HTTP exchange:{\n"+
+				"  requestID:    [%s]\n"+
+				"  method:       [%s]\n"+
+				"  endpoint:     [%s]\n"+
+				"  statusCode:   [%d]\n"+
+				"  latency:      [%.3fms]\n"+
+				"  error:        [%s]\n"+
+				"  reqHeaders:   [%v]\n"+
+				"  respHeaders:  [%v]\n"+
+				"  requestBody:  [%s]\n"+
+				"  responseBody: [%s]\n"+
+				"}",
+			entry.RequestID,
+			entry.Method,
+			entry.Endpoint,
+			entry.Status,
+			entry.LatencyMS,
+			entry.Error,
+			entry.RequestHeader,
+			entry.ResponseHeader,
+			entry.Request,
+			entry.Response,
+		)
+		return
+	}
+
+	entry.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	entry.Level = "INFO"
+	if entry.Error != "" || entry.Status >= 500 {
+		entry.Level = "ERROR"
+	}
+	line, jsonEncErr := json.Marshal(entry)
+	if jsonEncErr != nil {
+		log.Errorf("Unable to encode log entry: %s", jsonEncErr)
+		return
+	}
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	transport.output.Write(append(line, '\n'))
+}
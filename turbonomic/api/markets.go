@@ -1,8 +1,8 @@
 package api
 
 import (
+	"context"
 	"fmt"
-	"net/http"
 
 	log "github.com/foo/terraform-provider-utils/log"
 )
@@ -26,6 +26,10 @@ type TurboMarket struct {
 	EnvironmentType  string `json:"environmentType,omitempty"`
 }
 
+// CriteriaList - criterion of a dynamic group or a search, ie: the display
+// names matching a regular expression:
+//
+//   CriteriaList{FilterType: "vmsByName", ExpType: ExpTypeRegexEquals, ExpVal: "^web-.*"}
 type CriteriaList struct {
 	ExpVal        string `json:"expVal,omitempty"`
 	ExpType       string `json:"expType,omitempty"`
@@ -66,32 +70,27 @@ type TurboMarketPolicy struct {
 // -----------------------------------------------------------------------------
 
 // ReadMarket - reads the attributes of a TURBO Market
-// identified by the supplied name
-func (c *Client) ReadMarket(marketName string) (*TurboMarket, error) {
+// identified by the supplied name. Pages of the market list are read until
+// the market is found.
+func (c *Client) ReadMarket(ctx context.Context, marketName string) (*TurboMarket, error) {
 	log.Tracef("turbonomic/api/markets.go#ReadMarket")
 
 	reqEndpoint := fmt.Sprintf("/%s", MarketsPrefix)
 
-	req, reqErr := c.NewRequest(
-		http.MethodGet,
-		reqEndpoint,
-		nil,
-	)
-	if reqErr != nil {
-		return nil, reqErr
-	}
-
-	var markets []TurboMarket
-	sendErr := c.SendAndParse(req, &markets)
-	if sendErr != nil {
-		return nil, sendErr
-	}
+	it := c.NewListIterator(reqEndpoint, nil)
+	for it.HasNext() {
+		var markets []TurboMarket
+		pageErr := it.Next(ctx, &markets)
+		if pageErr != nil {
+			return nil, pageErr
+		}
 
-	log.Debugf("markets: [%+v]", markets)
+		log.Debugf("markets: [%+v]", markets)
 
-	for _, e := range markets {
-		if e.DisplayName == marketName {
-			return &e, nil
+		for _, e := range markets {
+			if e.DisplayName == marketName {
+				return &e, nil
+			}
 		}
 	}
 
@@ -102,32 +101,27 @@ func (c *Client) ReadMarket(marketName string) (*TurboMarket, error) {
 }
 
 // ReadMarketPolicy- reads the attributes of a TURBO Market
-// identified by the supplied name
-func (c *Client) ReadMarketPolicy(policyName string, marketUUID string) (*TurboMarketPolicy, error) {
+// identified by the supplied name. Pages of the policy list are read until
+// the policy is found.
+func (c *Client) ReadMarketPolicy(ctx context.Context, policyName string, marketUUID string) (*TurboMarketPolicy, error) {
 	log.Tracef("turbonomic/api/markets.go#ReadMarketPolicy")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s/policies", MarketsPrefix, marketUUID)
 
-	req, reqErr := c.NewRequest(
-		http.MethodGet,
-		reqEndpoint,
-		nil,
-	)
-	if reqErr != nil {
-		return nil, reqErr
-	}
-
-	var policies []TurboMarketPolicy
-	sendErr := c.SendAndParse(req, &policies)
-	if sendErr != nil {
-		return nil, sendErr
-	}
+	it := c.NewListIterator(reqEndpoint, nil)
+	for it.HasNext() {
+		var policies []TurboMarketPolicy
+		pageErr := it.Next(ctx, &policies)
+		if pageErr != nil {
+			return nil, pageErr
+		}
 
-	log.Debugf("policies: [%+v]", policies)
+		log.Debugf("policies: [%+v]", policies)
 
-	for _, e := range policies {
-		if e.DisplayName == policyName {
-			return &e, nil
+		for _, e := range policies {
+			if e.DisplayName == policyName {
+				return &e, nil
+			}
 		}
 	}
 	return nil, fmt.Errorf(
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// idSegmentPattern - path segments holding object identifiers rather than
+	// endpoint names, ie: "_58zFA7GKEeiLOK9ARx98hA" or "74218762382080"
+	idSegmentPattern = regexp.MustCompile(`[0-9_]`)
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// EndpointStats - counters of the requests sent to an endpoint
+type EndpointStats struct {
+	// Method and templated path of the endpoint, ie: "GET /templates/{uuid}"
+	Endpoint string
+	// Number of requests sent
+	Requests int
+	// Number of requests that failed or got a non-2xx response
+	Errors int
+	// Total and maximum latency of the requests, including retries
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// AverageLatency returns the mean latency of the requests
+func (stats EndpointStats) AverageLatency() time.Duration {
+	if stats.Requests == 0 {
+		return 0
+	}
+	return stats.TotalLatency / time.Duration(stats.Requests)
+}
+
+// Metrics counts the requests, errors and latency of every endpoint called
+// through the clients it is installed on.  It is safe for concurrent use.
+type Metrics struct {
+	// Path prefix stripped from the endpoints, ie: APIURLPrefix
+	basePath string
+
+	mutex     sync.Mutex
+	endpoints map[string]*EndpointStats
+}
+
+// NewMetrics returns empty Metrics.  Endpoints are reported relative to
+// basePath, which defaults to APIURLPrefix.
+func NewMetrics(basePath string) *Metrics {
+	return &Metrics{
+		basePath:  normalizeBasePath(basePath),
+		endpoints: map[string]*EndpointStats{},
+	}
+}
+
+// endpointOf returns the method and templated path of the request's
+// endpoint.  Object identifiers are replaced with "{uuid}" so that requests
+// for different objects are counted together.
+func (metrics *Metrics) endpointOf(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, metrics.basePath)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for idx, segment := range segments {
+		// the first segment is always the collection
+		if idx > 0 && idSegmentPattern.MatchString(segment) {
+			segments[idx] = "{uuid}"
+		}
+	}
+	return req.Method + " /" + strings.Join(segments, "/")
+}
+
+// Middleware returns the middleware recording the requests
+func (metrics *Metrics) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*Response, error) {
+			start := time.Now()
+			resp, respErr := next(req)
+			metrics.record(
+				metrics.endpointOf(req),
+				time.Since(start),
+				respErr != nil || resp.StatusCode < 200 || resp.StatusCode > 299,
+			)
+			return resp, respErr
+		}
+	}
+}
+
+// record adds a request to the counters of the endpoint
+func (metrics *Metrics) record(endpoint string, latency time.Duration, failed bool) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	stats, ok := metrics.endpoints[endpoint]
+	if !ok {
+		stats = &EndpointStats{Endpoint: endpoint}
+		metrics.endpoints[endpoint] = stats
+	}
+	stats.Requests++
+	if failed {
+		stats.Errors++
+	}
+	stats.TotalLatency += latency
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+}
+
+// Snapshot returns the counters of every endpoint called, slowest total
+// latency first
+func (metrics *Metrics) Snapshot() []EndpointStats {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	snapshot := make([]EndpointStats, 0, len(metrics.endpoints))
+	for _, stats := range metrics.endpoints {
+		snapshot = append(snapshot, *stats)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].TotalLatency != snapshot[j].TotalLatency {
+			return snapshot[i].TotalLatency > snapshot[j].TotalLatency
+		}
+		return snapshot[i].Endpoint < snapshot[j].Endpoint
+	})
+	return snapshot
+}
+
+// Summary returns a table of the counters of every endpoint called, slowest
+// total latency first.  Returns an empty string if no request was recorded.
+func (metrics *Metrics) Summary() string {
+	snapshot := metrics.Snapshot()
+	if len(snapshot) == 0 {
+		return ""
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(
+		&summary,
+		"%-45s %8s %6s %10s %10s %10s\n",
+		"ENDPOINT", "REQUESTS", "ERRORS", "TOTAL", "AVG", "MAX",
+	)
+	for _, stats := range snapshot {
+		fmt.Fprintf(
+			&summary,
+			"%-45s %8d %6d %10s %10s %10s\n",
+			stats.Endpoint,
+			stats.Requests,
+			stats.Errors,
+			stats.TotalLatency.Round(time.Millisecond),
+			stats.AverageLatency().Round(time.Millisecond),
+			stats.MaxLatency.Round(time.Millisecond),
+		)
+	}
+	return summary.String()
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// Response - response of the server to a request sent by the client
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	// Entire response body
+	Body []byte
+}
+
+// Handler sends a request to the server and returns its response.  A
+// non-2xx response is not an error at this level.
+type Handler func(req *http.Request) (*Response, error)
+
+// Middleware wraps the Handler sending every request made through
+// Client.Send, ie: to add headers, time requests or count errors.  A
+// middleware sees each request once: the cache, authentication and retries
+// happen further down the chain.
+//
+// A middleware calls next to continue the chain, and may modify the request
+// beforehand or the response afterwards:
+//
+//   func CorrelationID(id string) api.Middleware {
+//     return func(next api.Handler) api.Handler {
+//       return func(req *http.Request) (*api.Response, error) {
+//         req.Header.Set("X-Correlation-ID", id)
+//         return next(req)
+//       }
+//     }
+//   }
+type Middleware func(next Handler) Handler
+
+// chainMiddlewares wraps the handler with the middlewares.  The first
+// middleware is the outermost one and sees requests first.
+func chainMiddlewares(handler Handler, middlewares []Middleware) Handler {
+	for idx := len(middlewares) - 1; idx >= 0; idx-- {
+		handler = middlewares[idx](handler)
+	}
+	return handler
+}
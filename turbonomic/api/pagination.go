@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+const (
+	// DefaultPageSize - number of objects requested per page from list
+	// endpoints when the ClientOptions do not set one
+	DefaultPageSize = 500
+	// NextCursorHeader - response header carrying the cursor of the next
+	// page.  The header is absent (or empty) on the last page.
+	NextCursorHeader = "X-Next-Cursor"
+	// Query parameters understood by paginated list endpoints
+	cursorQueryParam = "cursor"
+	limitQueryParam  = "limit"
+)
+
+// -----------------------------------------------------------------------------
+// Iterator
+// -----------------------------------------------------------------------------
+
+// ListIterator walks a paginated Turbonomic list endpoint one page at a time.
+// The endpoint is expected to accept the `cursor` and `limit` query
+// parameters and to return the cursor of the next page in the X-Next-Cursor
+// header.  Endpoints that ignore these parameters, and servers without the
+// CursorPagination capability, are read as a single page.
+//
+// Typical usage:
+//
+//   it := client.NewListIterator(TemplatesPrefix, nil)
+//   for it.HasNext() {
+//     var page []TemplateApiDTO
+//     if err := it.Next(ctx, &page); err != nil {
+//       return err
+//     }
+//     ...
+//   }
+type ListIterator struct {
+	client *Client
+	// Endpoint relative to the API URL prefix.  See Client.NewRequest.
+	endpoint string
+	// Additional query parameters sent with every page request
+	query url.Values
+	// Method and body of the page requests.  Search endpoints take their
+	// criteria in a POST body.
+	method string
+	body   []byte
+	// Cursor of the next page.  Empty for the first page.
+	cursor string
+	// Cursors already visited, used to detect servers that never stop
+	// paginating
+	seen map[string]bool
+	// Whether or not the last page has been read
+	done bool
+}
+
+// NewListIterator returns a ListIterator over the given list endpoint.  The
+// query parameters (which may be nil) are sent with every page request.
+func (client *Client) NewListIterator(endpoint string, query url.Values) *ListIterator {
+	return &ListIterator{
+		client:   client,
+		endpoint: endpoint,
+		query:    query,
+		method:   http.MethodGet,
+		seen:     map[string]bool{},
+	}
+}
+
+// newPostListIterator returns a ListIterator over a list endpoint that takes
+// the given body in a POST request, ie: /search with criteria.  The same body
+// is sent with every page request.
+func (client *Client) newPostListIterator(endpoint string, query url.Values, body []byte) *ListIterator {
+	it := client.NewListIterator(endpoint, query)
+	it.method = http.MethodPost
+	it.body = body
+	return it
+}
+
+// HasNext reports whether there are more pages to read.
+func (it *ListIterator) HasNext() bool {
+	return !it.done
+}
+
+// Next reads the next page and unmarshals it into page, which should be a
+// pointer to a slice.  Calling Next after the last page returns an error.
+func (it *ListIterator) Next(ctx context.Context, page interface{}) error {
+	log.Tracef("turbonomic/api/pagination.go#Next")
+
+	if it.done {
+		return fmt.Errorf("No more pages to read from [%s]", it.endpoint)
+	}
+
+	var reqBody io.Reader
+	if it.body != nil {
+		reqBody = bytes.NewReader(it.body)
+	}
+	req, reqErr := it.client.NewRequest(
+		ctx,
+		it.method,
+		it.endpoint,
+		reqBody,
+	)
+	if reqErr != nil {
+		return reqErr
+	}
+
+	reqQuery := req.URL.Query()
+	for key, values := range it.query {
+		for _, value := range values {
+			reqQuery.Add(key, value)
+		}
+	}
+	// Servers without cursor pagination return every object at once and may
+	// reject unknown query parameters
+	if it.client.Capabilities().CursorPagination {
+		reqQuery.Set(limitQueryParam, strconv.Itoa(it.client.options.PageSize))
+		if it.cursor != "" {
+			reqQuery.Set(cursorQueryParam, it.cursor)
+		}
+	}
+	req.URL.RawQuery = reqQuery.Encode()
+
+	header, sendErr := it.client.sendAndParse(req, page)
+	if sendErr != nil {
+		return sendErr
+	}
+
+	nextCursor := header.Get(NextCursorHeader)
+	log.Debugf("[%s] next cursor: [%s]", it.endpoint, nextCursor)
+	if nextCursor == "" {
+		it.done = true
+		return nil
+	}
+	if it.seen[nextCursor] {
+		it.done = true
+		return fmt.Errorf(
+			"Endpoint [%s] returned cursor [%s] more than once",
+			it.endpoint,
+			nextCursor,
+		)
+	}
+	it.seen[nextCursor] = true
+	it.cursor = nextCursor
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Collect All
+// -----------------------------------------------------------------------------
+
+// ListAll reads every page of the given list endpoint and unmarshals the
+// combined result into objs, which should be a pointer to a slice.  The query
+// parameters (which may be nil) are sent with every page request.
+func (client *Client) ListAll(ctx context.Context, endpoint string, query url.Values, objs interface{}) error {
+	log.Tracef("turbonomic/api/pagination.go#ListAll")
+
+	return readAll(ctx, client.NewListIterator(endpoint, query), objs)
+}
+
+// readAll reads every page of the iterator and unmarshals the combined
+// result into objs, which should be a pointer to a slice.
+func readAll(ctx context.Context, it *ListIterator, objs interface{}) error {
+	// Pages are collected as raw JSON and decoded into the caller's type once
+	// all of them have been read.
+	all := []json.RawMessage{}
+	for it.HasNext() {
+		var page []json.RawMessage
+		if pageErr := it.Next(ctx, &page); pageErr != nil {
+			return pageErr
+		}
+		all = append(all, page...)
+	}
+	log.Debugf("[%s] read [%d] objects", it.endpoint, len(all))
+
+	allJSONBytes, jsonEncErr := json.Marshal(all)
+	if jsonEncErr != nil {
+		return jsonEncErr
+	}
+	return json.Unmarshal(allJSONBytes, objs)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+// Stages of the connection to Turbonomic reported by ProbeError
+const (
+	// The server's hostname could not be resolved
+	ProbeStageDNS = "DNS"
+	// No connection could be established with the server
+	ProbeStageConnection = "connection"
+	// The TLS handshake failed, ie: an untrusted certificate
+	ProbeStageTLS = "TLS"
+	// The server rejected the credentials
+	ProbeStageAuthentication = "authentication"
+	// The server answered, but not as the Turbonomic API
+	ProbeStageAPIPath = "API path"
+	// Any other failure
+	ProbeStageRequest = "request"
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// ProbeError is returned by Client.Probe.  It records which stage of the
+// connection to Turbonomic failed along with a hint on how to fix it.
+type ProbeError struct {
+	// One of the ProbeStageXxx constants
+	Stage string
+	// Suggested fix
+	Hint string
+	// Underlying error
+	Err error
+}
+
+// Error implements the error interface
+func (e *ProbeError) Error() string {
+	return fmt.Sprintf(
+		"Turbonomic %s check failed: %s\n%s",
+		e.Stage,
+		e.Err.Error(),
+		e.Hint,
+	)
+}
+
+// Unwrap returns the underlying error
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// -----------------------------------------------------------------------------
+// Probe
+// -----------------------------------------------------------------------------
+
+// Probe verifies that the client can reach and authenticate against the
+// Turbonomic API by detecting the server version (see DetectVersion).
+// Failures are returned as a *ProbeError describing what failed.
+func (client *Client) Probe(ctx context.Context) (*ServerVersion, error) {
+	log.Tracef("turbonomic/api/probe.go#Probe")
+
+	version, versionErr := client.DetectVersion(ctx)
+	if versionErr != nil {
+		probeErr := client.classifyProbeError(versionErr)
+		log.Errorf("Turbonomic %s check failed: %s", probeErr.Stage, versionErr)
+		return nil, probeErr
+	}
+	return version, nil
+}
+
+// classifyProbeError maps an error returned while probing the server to the
+// stage of the connection that failed.
+func (client *Client) classifyProbeError(err error) *ProbeError {
+	probeErr := &ProbeError{Stage: ProbeStageRequest, Err: err}
+
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateErr x509.CertificateInvalidError
+	var opErr *net.OpError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var formatErr *versionFormatError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		probeErr.Stage = ProbeStageDNS
+		probeErr.Hint = fmt.Sprintf(
+			"The hostname [%s] could not be resolved. Check server_hostname or server_url.",
+			client.Server.Hostname(),
+		)
+	case errors.As(err, &recordErr),
+		errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &certificateErr),
+		strings.Contains(err.Error(), "tls:"),
+		strings.Contains(err.Error(), "HTTP response to HTTPS client"):
+		probeErr.Stage = ProbeStageTLS
+		probeErr.Hint = "Check server_protocol, client_ca_bundle and " +
+			"client_tls_server_name, or set client_tls_insecure to skip verification."
+	case errors.As(err, &opErr), errors.Is(err, context.DeadlineExceeded):
+		probeErr.Stage = ProbeStageConnection
+		probeErr.Hint = fmt.Sprintf(
+			"No connection could be established with [%s]. Check the port, "+
+				"http_proxy and any firewall between Terraform and Turbonomic.",
+			client.Server.Host,
+		)
+	case IsUnauthorized(err), IsForbidden(err):
+		probeErr.Stage = ProbeStageAuthentication
+		probeErr.Hint = fmt.Sprintf(
+			"The [%s] credentials were rejected. Check the client credentials "+
+				"and the permissions of the account.",
+			client.credentials.AuthMethod,
+		)
+	case IsNotFound(err),
+		errors.As(err, &syntaxErr),
+		errors.As(err, &typeErr),
+		errors.As(err, &formatErr):
+		probeErr.Stage = ProbeStageAPIPath
+		probeErr.Hint = fmt.Sprintf(
+			"[%s%s] did not answer as the Turbonomic API. Check api_base_path "+
+				"and server_url.",
+			client.Server.String(),
+			client.options.APIBasePath,
+		)
+	default:
+		probeErr.Hint = "Check the provider log for the details of the request."
+	}
+	return probeErr
+}
+
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Token Bucket Rate Limiter
+// -----------------------------------------------------------------------------
+
+// rateLimiter is a token bucket shared by every request issued by a Client.
+// The bucket holds up to burst tokens and refills at rate tokens per second.
+// Every request takes one token, waiting for the bucket to refill if it is
+// empty.
+type rateLimiter struct {
+	mutex sync.Mutex
+	// Tokens added to the bucket per second
+	rate float64
+	// Maximum number of tokens the bucket holds
+	burst float64
+	// Tokens currently in the bucket.  Negative when requests have reserved
+	// tokens that have not been refilled yet.
+	tokens float64
+	// Last time the bucket was refilled
+	last time.Time
+}
+
+// newRateLimiter returns a rate limiter allowing requestsPerSecond requests
+// per second on average, with bursts of up to burst requests.  A burst
+// smaller than one is treated as one.
+func newRateLimiter(requestsPerSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available or the context is done.
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	limiter.mutex.Lock()
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+	// Reserve a token.  If the bucket is empty, the reservation is paid back
+	// by the refill after the computed delay.
+	limiter.tokens--
+	var delay time.Duration
+	if limiter.tokens < 0 {
+		delay = time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+	}
+	limiter.mutex.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+		// give back the unused reservation
+		limiter.mutex.Lock()
+		limiter.tokens++
+		limiter.mutex.Unlock()
+		return sleepErr
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Concurrency Limiter
+// -----------------------------------------------------------------------------
+
+// concurrencyLimiter caps the number of requests in flight at once
+type concurrencyLimiter chan struct{}
+
+// newConcurrencyLimiter returns a limiter allowing up to maxInFlight
+// concurrent requests.
+func newConcurrencyLimiter(maxInFlight int) concurrencyLimiter {
+	return make(concurrencyLimiter, maxInFlight)
+}
+
+// acquire blocks until a slot is free or the context is done.  Every
+// successful acquire must be paired with a release.
+func (limiter concurrencyLimiter) acquire(ctx context.Context) error {
+	select {
+	case limiter <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (limiter concurrencyLimiter) release() {
+	<-limiter
+}
+
+// -----------------------------------------------------------------------------
+// Client Integration
+// -----------------------------------------------------------------------------
+
+// throttledTransport is an http.RoundTripper applying the rate and
+// concurrency limits to every round trip made by the client's HTTP client,
+// including logins, token requests, retries and replays.
+type throttledTransport struct {
+	// Limits shared by every request.  Either may be nil.
+	rateLimiter        *rateLimiter
+	concurrencyLimiter concurrencyLimiter
+	// Transport performing the actual round trip
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.  The concurrency slot is held
+// until the response body is closed.
+func (transport *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	release := func() {}
+	if transport.concurrencyLimiter != nil {
+		if acquireErr := transport.concurrencyLimiter.acquire(ctx); acquireErr != nil {
+			return nil, acquireErr
+		}
+		var once sync.Once
+		release = func() { once.Do(transport.concurrencyLimiter.release) }
+	}
+	if transport.rateLimiter != nil {
+		if waitErr := transport.rateLimiter.wait(ctx); waitErr != nil {
+			release()
+			return nil, waitErr
+		}
+	}
+
+	resp, respErr := transport.next.RoundTrip(req)
+	if respErr != nil {
+		release()
+		return nil, respErr
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releaseOnClose calls release when the wrapped response body is closed
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close implements io.Closer
+func (body *releaseOnClose) Close() error {
+	closeErr := body.ReadCloser.Close()
+	body.release()
+	return closeErr
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -63,16 +64,20 @@ type ReservationParameter struct {
 
 type ReservationDeploymentParameter struct {
 	DeploymentProfileID string `json:"deploymentProfileID,omitempty"`
-	HighAvailability    bool   `json:"highAvailability,omitempty"`
-	Priority            bool   `json:"priority,omitempty"`
+	// Whether the workloads are placed on distinct hosts
+	HighAvailability bool `json:"highAvailability,omitempty"`
+	// Priority of the reservation, ie: "HIGH".  A bool before reservations
+	// exposed it: callers that set it to true must set a priority instead.
+	Priority string `json:"priority,omitempty"`
 }
 
 type ReservationPlacementParameter struct {
-	ConstraintIDs        []string `json:"constraintIDs,omitempty"`
-	Count                int      `json:"count,omitempty"`
-	EntityNames          []string `json:"entityNames,omitempty"`
-	GeographicRedundancy bool     `json:"geographicRedundancy,omitempty"`
-	TemplateID           string   `json:"templateID,omitempty"`
+	ConstraintIDs []string `json:"constraintIDs,omitempty"`
+	Count         int      `json:"count,omitempty"`
+	EntityNames   []string `json:"entityNames,omitempty"`
+	// Whether the workloads are placed in distinct datacenters
+	GeographicRedundancy bool   `json:"geographicRedundancy,omitempty"`
+	TemplateID           string `json:"templateID,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -105,14 +110,14 @@ type Placement struct {
 }
 
 type ComputeResource struct {
-	Provider Identifier `json:"provider,omitempty"`
-	Stats    []stat     `json:"stats,omitempty"`
+	Provider Identifier   `json:"provider,omitempty"`
+	Stats    []StatApiDTO `json:"stats,omitempty"`
 }
 
 type StorageResource struct {
-	Provider Identifier `json:"provider,omitempty"`
-	Stats    []stat     `json:"stats,omitempty"`
-	Type     string     `json:"type,omitempty"`
+	Provider Identifier   `json:"provider,omitempty"`
+	Stats    []StatApiDTO `json:"stats,omitempty"`
+	Type     string       `json:"type,omitempty"`
 }
 
 // Identifier - generic object idenitfier block
@@ -122,11 +127,6 @@ type Identifier struct {
 	ClassName   string `json:"className,omitempty"`
 }
 
-type stat struct {
-	Name  string  `json:"name,omitempty"`
-	Value float32 `json:"value,omitempty"`
-}
-
 // {
 // 	"uuid": "_58zFA7GKEeiLOK9ARx98hA",
 // 	"displayName": "ishashchuk-test",
@@ -218,12 +218,13 @@ type stat struct {
 
 // DeleteReservation deletes the Turbonomic reservation identitied by the given
 // UUID. It will return an error if encountered.
-func (c *Client) DeleteReservation(uuid string) error {
+func (c *Client) DeleteReservation(ctx context.Context, uuid string) error {
 	log.Tracef("turbonomic/api/reservations.go#DeleteReservation")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s", ReservationsPrefix, uuid)
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodDelete,
 		reqEndpoint,
 		nil,
@@ -237,12 +238,13 @@ func (c *Client) DeleteReservation(uuid string) error {
 
 // ReadReservation reads the Turbonomic reservation identitied by the given
 // UUID. It will return an error if encountered.
-func (c *Client) ReadReservation(uuid string) (*ReservationResponse, error) {
+func (c *Client) ReadReservation(ctx context.Context, uuid string) (*ReservationResponse, error) {
 	log.Tracef("turbonomic/api/reservations.go#ReadReservation")
 
 	reqEndPoint := fmt.Sprintf("/%s/%s", ReservationsPrefix, uuid)
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodGet,
 		reqEndPoint,
 		nil,
@@ -265,13 +267,38 @@ func (c *Client) ReadReservation(uuid string) (*ReservationResponse, error) {
 	return &response, nil
 }
 
+// CheckReservationCapabilities returns an UnsupportedError if the
+// reservation relies on a feature the Turbonomic server does not support.
+func (c *Client) CheckReservationCapabilities(rCreate *ReservationCreate) error {
+	capabilities := c.Capabilities()
+	if rCreate.DeployDateTime != "" && !capabilities.ReservationDeployDateTime {
+		return c.unsupported("Reservation deployDateTime")
+	}
+	for _, param := range rCreate.Parameters {
+		if param.DeploymentParameters.HighAvailability && !capabilities.ReservationHighAvailability {
+			return c.unsupported("Reservation highAvailability")
+		}
+		if param.DeploymentParameters.Priority != "" && !capabilities.ReservationPriority {
+			return c.unsupported("Reservation priority")
+		}
+		if param.PlacementParameters.GeographicRedundancy && !capabilities.ReservationGeographicRedundancy {
+			return c.unsupported("Reservation geographicRedundancy")
+		}
+	}
+	return nil
+}
+
 // CreateReservation creates a new Turbonomic reservation with the
 // provided ReservationCreate object. It returns a reference to the ReservationResponse object
 // that was returned, representing the created template or an error if
 // encountered.
-func (c *Client) CreateReservation(rCreate *ReservationCreate, blocking bool) (*ReservationResponse, error) {
+func (c *Client) CreateReservation(ctx context.Context, rCreate *ReservationCreate, blocking bool) (*ReservationResponse, error) {
 	log.Tracef("turbonomic/api/reservations.go#ReservationCreate")
 
+	if unsupportedErr := c.CheckReservationCapabilities(rCreate); unsupportedErr != nil {
+		return nil, unsupportedErr
+	}
+
 	reqEndPoint := fmt.Sprintf("/%s", ReservationsPrefix)
 
 	resJSON, jsonEncErr := json.Marshal(rCreate)
@@ -282,6 +309,7 @@ func (c *Client) CreateReservation(rCreate *ReservationCreate, blocking bool) (*
 	log.Debugf("rCreate: [%s]", resJSON)
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodPost,
 		reqEndPoint,
 		bytes.NewBuffer(resJSON),
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Retry defaults used when the ClientOptions leave them unset
+const (
+	// DefaultRetryMinWait - initial wait between retries
+	DefaultRetryMinWait = 1 * time.Second
+	// DefaultRetryMaxWait - upper bound for the wait between retries
+	DefaultRetryMaxWait = 30 * time.Second
+)
+
+// retryWait determines whether a request should be retried after the given
+// attempt (starting at 0) and how long to wait before retrying.
+func (client *Client) retryWait(req *http.Request, attempt int, statusCode int, header http.Header, sendErr error) (time.Duration, bool) {
+	if attempt >= client.options.MaxRetries {
+		return 0, false
+	}
+	// the caller gave up: canceled or past its deadline
+	if req.Context().Err() != nil {
+		return 0, false
+	}
+	if !client.options.RetryNonIdempotent && !isIdempotentMethod(req.Method) {
+		return 0, false
+	}
+	if sendErr != nil {
+		if !isRetryableError(sendErr) {
+			return 0, false
+		}
+	} else if !isRetryableStatus(statusCode) {
+		return 0, false
+	}
+
+	wait := backoff(client.options.RetryMinWait, client.options.RetryMaxWait, attempt)
+	if serverWait, ok := retryAfter(header); ok {
+		wait = serverWait
+		if wait > client.options.RetryMaxWait {
+			wait = client.options.RetryMaxWait
+		}
+	}
+	return wait, true
+}
+
+// isIdempotentMethod reports whether sending a request with the given method
+// more than once has the same effect as sending it once.
+func isIdempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet,
+		http.MethodHead,
+		http.MethodPut,
+		http.MethodDelete,
+		http.MethodOptions,
+		http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// isRetryableStatus reports whether the server's response indicates a
+// transient condition.
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRetryableError reports whether an error returned while sending a request
+// or reading its response is transient: timeouts, connections reset or
+// closed by the server, and truncated responses.  Any other error, such as an
+// invalid URL, a certificate failure or an undecodable token response, will
+// recur on every attempt and is returned immediately.
+func isRetryableError(err error) bool {
+	// rejected logins and token requests
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.StatusCode)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// the server closed a kept-alive connection before responding
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && urlErr.Err == io.EOF
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, convErr := strconv.Atoi(value); convErr == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, parseErr := http.ParseTime(value); parseErr == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// backoff returns the wait before the retry following the given attempt.
+// The wait grows exponentially from minWait, is capped at maxWait, and is
+// jittered to between half and all of that value so that parallel requests
+// failing together do not retry in lockstep.
+func backoff(minWait time.Duration, maxWait time.Duration, attempt int) time.Duration {
+	wait := minWait
+	for i := 0; i < attempt && wait < maxWait; i++ {
+		wait *= 2
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+	half := int64(wait / 2)
+	if half <= 0 {
+		return wait
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// sleepContext waits for the given duration or until the context is done,
+// whichever happens first.  It returns the context's error if the wait was
+// cut short.
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
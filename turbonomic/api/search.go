@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+const (
+	// SearchPrefix - API endpoint searching objects server-side
+	SearchPrefix = "search"
+	// SearchCriteriaPrefix - API endpoint listing the criteria filter types
+	// understood by SearchPrefix
+	SearchCriteriaPrefix = "search/criteria"
+
+	// Expression types of a CriteriaList
+	ExpTypeEquals         = "EQ"
+	ExpTypeNotEquals      = "NEQ"
+	ExpTypeRegexEquals    = "RXEQ"
+	ExpTypeRegexNotEquals = "RXNEQ"
+
+	// Logical operators combining the criteria of a search
+	LogicalOperatorAnd = "AND"
+	LogicalOperatorOr  = "OR"
+
+	// SearchTypeDeploymentProfile - search type of deployment profiles
+	SearchTypeDeploymentProfile = "ServiceCatalogItem"
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// SearchQuery describes a server-side search.  Every field is optional; the
+// empty query matches every object the server can search.
+type SearchQuery struct {
+	// Regular expression matched against the display names, ie: "^PuREST
+	// VM$".  See ExactNameRegex.
+	NameRegex string
+	// Class names of the objects searched, ie: "VirtualMachineProfile"
+	Types []string
+	// UUIDs of the groups, markets or targets limiting the search
+	Scopes []string
+	// States of the objects searched, ie: "ACTIVE"
+	States []string
+	// Environment of the objects searched, ie: "ONPREM" or "CLOUD"
+	EnvironmentType string
+
+	// Criteria filtering the objects further.  Queries with criteria are
+	// POSTed to the server, and their ClassName defaults to the first type.
+	Criteria []CriteriaList
+	// Operator combining the criteria, defaults to LogicalOperatorAnd
+	LogicalOperator string
+	// Class name of the objects matched by the criteria
+	ClassName string
+}
+
+// searchCriteriaInput - body of the POST requests of searches with criteria
+type searchCriteriaInput struct {
+	ClassName       string         `json:"className,omitempty"`
+	CriteriaList    []CriteriaList `json:"criteriaList"`
+	LogicalOperator string         `json:"logicalOperator,omitempty"`
+}
+
+// SearchCriterionApiDTO - filter type understood by the criteria of a search
+type SearchCriterionApiDTO struct {
+	// Class name of the objects the filter applies to
+	ElementType string `json:"elements,omitempty"`
+	// Filter type, used as CriteriaList.FilterType, ie: "vmsByName"
+	FilterType string `json:"filterType,omitempty"`
+	// Expression types accepted by the filter, ie: ["EQ", "RXEQ"]
+	ExpTypes []string `json:"expTypes,omitempty"`
+	// Whether or not the filter is on a display name
+	IsNameFilter bool `json:"isNameFilter,omitempty"`
+}
+
+// ExactNameRegex returns the regular expression matching the given display
+// name and nothing else
+func ExactNameRegex(name string) string {
+	return "^" + regexp.QuoteMeta(name) + "$"
+}
+
+// values returns the query parameters of the search
+func (query SearchQuery) values() url.Values {
+	values := url.Values{}
+	if query.NameRegex != "" {
+		values.Set("q", query.NameRegex)
+	}
+	if len(query.Types) > 0 {
+		values.Set("types", strings.Join(query.Types, ","))
+	}
+	if len(query.Scopes) > 0 {
+		values.Set("scopes", strings.Join(query.Scopes, ","))
+	}
+	if len(query.States) > 0 {
+		values.Set("state", strings.Join(query.States, ","))
+	}
+	if query.EnvironmentType != "" {
+		values.Set("environment_type", query.EnvironmentType)
+	}
+	return values
+}
+
+// body returns the JSON body of the search, or nil if the query has no
+// criteria
+func (query SearchQuery) body() ([]byte, error) {
+	if len(query.Criteria) == 0 {
+		return nil, nil
+	}
+	input := searchCriteriaInput{
+		ClassName:       query.ClassName,
+		CriteriaList:    query.Criteria,
+		LogicalOperator: query.LogicalOperator,
+	}
+	if input.ClassName == "" && len(query.Types) > 0 {
+		input.ClassName = query.Types[0]
+	}
+	if input.LogicalOperator == "" {
+		input.LogicalOperator = LogicalOperatorAnd
+	}
+	return json.Marshal(input)
+}
+
+// -----------------------------------------------------------------------------
+// Search Implementation
+// -----------------------------------------------------------------------------
+
+// Search reads every object matching the query and unmarshals them into
+// objs, which should be a pointer to a slice.  All pages of the results are
+// read.
+func (c *Client) Search(ctx context.Context, query SearchQuery, objs interface{}) error {
+	log.Tracef("turbonomic/api/search.go#Search")
+
+	reqEndpoint := fmt.Sprintf("/%s", SearchPrefix)
+
+	body, jsonEncErr := query.body()
+	if jsonEncErr != nil {
+		return jsonEncErr
+	}
+	log.Debugf("search: [%s] [%s]", query.values().Encode(), body)
+
+	if body == nil {
+		return c.ListAll(ctx, reqEndpoint, query.values(), objs)
+	}
+	return readAll(ctx, c.newPostListIterator(reqEndpoint, query.values(), body), objs)
+}
+
+// SearchCriteria returns the filter types understood by the criteria of
+// searches on the given types, or on every type if none is given.
+func (c *Client) SearchCriteria(ctx context.Context, types ...string) ([]SearchCriterionApiDTO, error) {
+	log.Tracef("turbonomic/api/search.go#SearchCriteria")
+
+	reqEndpoint := fmt.Sprintf("/%s", SearchCriteriaPrefix)
+
+	query := url.Values{}
+	if len(types) > 0 {
+		query.Set("types", strings.Join(types, ","))
+	}
+
+	var criteria []SearchCriterionApiDTO
+	listErr := c.ListAll(ctx, reqEndpoint, query, &criteria)
+	if listErr != nil {
+		return nil, listErr
+	}
+	return criteria, nil
+}
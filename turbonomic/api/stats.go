@@ -0,0 +1,333 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+const (
+	// StatsPrefix - API endpoint for reading the historical, current and
+	// projected stats of an entity, a group or a market
+	StatsPrefix = "stats"
+	// StatDateNow - stat date of the current time
+	StatDateNow = "now"
+)
+
+var (
+	// relativeStatDatePattern - dates relative to the current time, ie: "-7d"
+	// or "+12h"
+	relativeStatDatePattern = regexp.MustCompile(`^([+-])(\d+)([hdw])$`)
+	// relativeStatDateUnits - durations of the units of relative dates
+	relativeStatDateUnits = map[string]time.Duration{
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// StatApiInputDTO - stat requested from the stats endpoints
+type StatApiInputDTO struct {
+	// Filters of the stat, ie: {"type": "key", "value": "..."}
+	Filters []StatFilterApiDTO `json:"filters,omitempty"`
+	// Fields the stats are grouped by, ie: "key"
+	GroupBy []string `json:"groupBy,omitempty"`
+	// Name of the stat, ie: "CPU", "Mem" or "StorageAmount"
+	Name string `json:"name,omitempty"`
+	// Type of the entities in the scope the stat is read from, ie:
+	// "PhysicalMachine" for the hosts of a cluster
+	RelatedEntityType string `json:"relatedEntityType,omitempty"`
+}
+
+// StatPeriodApiInputDTO - period and stats requested from the stats
+// endpoints.  A period in the future returns projected stats.  Omitting the
+// dates returns the current stats.
+type StatPeriodApiInputDTO struct {
+	// Dates in milliseconds since the epoch.  See FormatStatDate.
+	EndDate    string            `json:"endDate,omitempty"`
+	StartDate  string            `json:"startDate,omitempty"`
+	Statistics []StatApiInputDTO `json:"statistics,omitempty"`
+}
+
+// StatScopesApiInputDTO - scopes, period and stats requested from the
+// multi-scope stats endpoint
+type StatScopesApiInputDTO struct {
+	Period *StatPeriodApiInputDTO `json:"period,omitempty"`
+	// Type of the entities in the scopes the stats are read from, ie:
+	// "PhysicalMachine" for the hosts of clusters
+	RelatedType string `json:"relatedType,omitempty"`
+	// UUIDs of the entities, groups or markets
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// EntityStatsApiDTO - stat snapshots of an entity returned by the
+// multi-scope stats endpoint
+type EntityStatsApiDTO struct {
+	ClassName   string               `json:"className,omitempty"`
+	DisplayName string               `json:"displayName,omitempty"`
+	Stats       []StatSnapshotApiDTO `json:"stats,omitempty"`
+	UUID        string               `json:"uuid,omitempty"`
+}
+
+// ParseStatDate parses a stat date relative to now.  Accepted formats are
+// StatDateNow, offsets from now in hours, days or weeks (ie: "-7d" or
+// "+12h"), RFC 3339 timestamps, "2006-01-02" dates (UTC) and milliseconds
+// since the epoch.
+func ParseStatDate(value string, now time.Time) (time.Time, error) {
+	if value == StatDateNow {
+		return now, nil
+	}
+	if match := relativeStatDatePattern.FindStringSubmatch(value); match != nil {
+		count, _ := strconv.Atoi(match[2])
+		offset := time.Duration(count) * relativeStatDateUnits[match[3]]
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return now.Add(offset), nil
+	}
+	if parsed, parseErr := time.Parse(time.RFC3339, value); parseErr == nil {
+		return parsed, nil
+	}
+	if parsed, parseErr := time.Parse("2006-01-02", value); parseErr == nil {
+		return parsed, nil
+	}
+	if millis, parseErr := strconv.ParseUint(value, 10, 63); parseErr == nil {
+		return time.Unix(0, int64(millis)*int64(time.Millisecond)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid stat date [%s]", value)
+}
+
+// FormatStatDate formats a date as expected by the stats endpoints:
+// milliseconds since the epoch
+func FormatStatDate(date time.Time) string {
+	return strconv.FormatInt(date.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// -----------------------------------------------------------------------------
+// Rollup
+// -----------------------------------------------------------------------------
+
+// RollupStatSnapshots aggregates the snapshots into one snapshot per interval
+// and epoch, dated at the start of the interval (UTC), oldest first.  An
+// interval of zero aggregates all the snapshots of an epoch into one, dated
+// at the first snapshot.  A negative interval only sorts the snapshots.  The
+// stats of a bucket are aggregated by name, filters and
+// related entity: averages and totals are averaged, the minimum and maximum
+// are kept.  Returns an error if a snapshot date cannot be parsed.
+func RollupStatSnapshots(snapshots []StatSnapshotApiDTO, interval time.Duration) ([]StatSnapshotApiDTO, error) {
+	type bucket struct {
+		date     time.Time
+		snapshot StatSnapshotApiDTO
+		// Stats to aggregate, by statKey, in order of appearance
+		keys  []string
+		stats map[string][]StatApiDTO
+	}
+
+	dates := make([]time.Time, len(snapshots))
+	for idx, snapshot := range snapshots {
+		date, parseErr := ParseStatDate(snapshot.Date, time.Time{})
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		dates[idx] = date
+	}
+	if interval < 0 {
+		// sort the indexes rather than the snapshots so that they keep
+		// matching the parsed dates
+		indexes := make([]int, len(snapshots))
+		for idx := range indexes {
+			indexes[idx] = idx
+		}
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return dates[indexes[i]].Before(dates[indexes[j]])
+		})
+		sorted := make([]StatSnapshotApiDTO, len(snapshots))
+		for idx, original := range indexes {
+			sorted[idx] = snapshots[original]
+		}
+		return sorted, nil
+	}
+
+	buckets := map[string]*bucket{}
+	order := []*bucket{}
+	for idx, snapshot := range snapshots {
+		date := dates[idx]
+		start := date.UTC()
+		if interval > 0 {
+			start = start.Truncate(interval)
+		}
+		bucketKey := snapshot.Epoch
+		if interval > 0 {
+			bucketKey += "/" + start.Format(time.RFC3339)
+		}
+		b, ok := buckets[bucketKey]
+		if !ok {
+			b = &bucket{
+				date: start,
+				snapshot: StatSnapshotApiDTO{
+					Date:        start.Format(time.RFC3339),
+					DisplayName: snapshot.DisplayName,
+					Epoch:       snapshot.Epoch,
+				},
+				stats: map[string][]StatApiDTO{},
+			}
+			buckets[bucketKey] = b
+			order = append(order, b)
+		}
+		for _, stat := range snapshot.Statistics {
+			key := statKey(stat)
+			if _, ok := b.stats[key]; !ok {
+				b.keys = append(b.keys, key)
+			}
+			b.stats[key] = append(b.stats[key], stat)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].date.Before(order[j].date)
+	})
+	rollup := make([]StatSnapshotApiDTO, len(order))
+	for idx, b := range order {
+		b.snapshot.Statistics = make([]StatApiDTO, len(b.keys))
+		for statIdx, key := range b.keys {
+			b.snapshot.Statistics[statIdx] = rollupStats(b.stats[key])
+		}
+		rollup[idx] = b.snapshot
+	}
+	return rollup, nil
+}
+
+// statKey identifies the stats aggregated together by a rollup
+func statKey(stat StatApiDTO) string {
+	parts := []string{stat.Name}
+	if stat.RelatedEntity != nil {
+		parts = append(parts, stat.RelatedEntity.UUID)
+	}
+	for _, filter := range stat.Filters {
+		parts = append(parts, filter.Type+"="+filter.Value)
+	}
+	return strings.Join(parts, "/")
+}
+
+// rollupStats aggregates stats sharing a statKey into the first one
+func rollupStats(stats []StatApiDTO) StatApiDTO {
+	rollup := stats[0]
+	values := make([]*StatValueApiDTO, len(stats))
+	capacities := []*StatValueApiDTO{}
+	reserved := []*StatValueApiDTO{}
+	for idx, stat := range stats {
+		values[idx] = stat.Values
+		if stat.Values == nil {
+			// the value is the average when the stat has no values
+			values[idx] = &StatValueApiDTO{Avg: stat.Value, Max: stat.Value, Min: stat.Value, Total: stat.Value}
+		}
+		if stat.Capacity != nil {
+			capacities = append(capacities, stat.Capacity)
+		}
+		if stat.Reserved != nil {
+			reserved = append(reserved, stat.Reserved)
+		}
+	}
+	rollup.Values = rollupValues(values)
+	rollup.Value = rollup.Values.Avg
+	rollup.Capacity = rollupValues(capacities)
+	rollup.Reserved = rollupValues(reserved)
+	return rollup
+}
+
+// rollupValues averages the averages and totals and keeps the minimum and
+// maximum of the values.  Returns nil if there are no values.
+func rollupValues(values []*StatValueApiDTO) *StatValueApiDTO {
+	if len(values) == 0 {
+		return nil
+	}
+	rollup := StatValueApiDTO{
+		Max: StatFloat(math.Inf(-1)),
+		Min: StatFloat(math.Inf(1)),
+	}
+	for _, value := range values {
+		rollup.Avg += value.Avg / StatFloat(len(values))
+		rollup.Total += value.Total / StatFloat(len(values))
+		if value.Max > rollup.Max {
+			rollup.Max = value.Max
+		}
+		if value.Min < rollup.Min {
+			rollup.Min = value.Min
+		}
+	}
+	return &rollup
+}
+
+// -----------------------------------------------------------------------------
+// Read Implementation
+// -----------------------------------------------------------------------------
+
+// Stats returns the snapshots of the stats of the entity, group or market
+// identified by the supplied UUID over the given period, or an error if
+// encountered.  If the scope does not exist, the error satisfies IsNotFound.
+func (c *Client) Stats(ctx context.Context, uuid string, period *StatPeriodApiInputDTO) ([]StatSnapshotApiDTO, error) {
+	log.Tracef("turbonomic/api/stats.go#Stats")
+
+	reqEndpoint := fmt.Sprintf("/%s/%s", StatsPrefix, uuid)
+
+	objJSONBytes, jsonEncErr := json.Marshal(period)
+	if jsonEncErr != nil {
+		return nil, jsonEncErr
+	}
+
+	log.Debugf("statPeriod: [%s]", objJSONBytes)
+
+	req, reqErr := c.NewRequest(
+		ctx,
+		http.MethodPost,
+		reqEndpoint,
+		bytes.NewBuffer(objJSONBytes),
+	)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	var snapshots []StatSnapshotApiDTO
+	sendErr := c.SendAndParse(req, &snapshots)
+	if sendErr != nil {
+		return nil, sendErr
+	}
+
+	return snapshots, nil
+}
+
+// ScopesStats returns the stat snapshots of every entity in the given scopes
+// or an error if encountered.  All pages of the results are read.
+func (c *Client) ScopesStats(ctx context.Context, input *StatScopesApiInputDTO) ([]EntityStatsApiDTO, error) {
+	log.Tracef("turbonomic/api/stats.go#ScopesStats")
+
+	reqEndpoint := fmt.Sprintf("/%s", StatsPrefix)
+
+	objJSONBytes, jsonEncErr := json.Marshal(input)
+	if jsonEncErr != nil {
+		return nil, jsonEncErr
+	}
+
+	log.Debugf("statScopes: [%s]", objJSONBytes)
+
+	var entityStats []EntityStatsApiDTO
+	listErr := readAll(ctx, c.newPostListIterator(reqEndpoint, nil, objJSONBytes), &entityStats)
+	if listErr != nil {
+		return nil, listErr
+	}
+	return entityStats, nil
+}
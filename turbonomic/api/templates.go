@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -128,9 +129,13 @@ func (obj *TemplateApiDTO) TemplateApiInputDTO() (*TemplateApiInputDTO, error) {
 // provided TemplateApiInputDTO. It returns a reference to the TemplateApiDTO
 // that was returned, representing the created template or an error if
 // encountered.
-func (c *Client) CreateTemplate(obj *TemplateApiInputDTO) (*TemplateApiDTO, error) {
+func (c *Client) CreateTemplate(ctx context.Context, obj *TemplateApiInputDTO) (*TemplateApiDTO, error) {
 	log.Tracef("turbonomic/api/templates.go#CreateTemplate")
 
+	if obj.ClassName != "" && !c.Capabilities().SupportsTemplateClass(obj.ClassName) {
+		return nil, c.unsupported(fmt.Sprintf("Template class [%s]", obj.ClassName))
+	}
+
 	reqEndpoint := fmt.Sprintf("/%s", TemplatesPrefix)
 
 	objJSONBytes, jsonEncErr := json.Marshal(obj)
@@ -142,6 +147,7 @@ func (c *Client) CreateTemplate(obj *TemplateApiInputDTO) (*TemplateApiDTO, erro
 	}
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodPost,
 		reqEndpoint,
 		bytes.NewBuffer(objJSONBytes),
@@ -160,13 +166,15 @@ func (c *Client) CreateTemplate(obj *TemplateApiInputDTO) (*TemplateApiDTO, erro
 }
 
 // ReadTemplate returns a TemplateApiDTO representing the template identified
-// by the supplied UUID or an error if encountered.
-func (c *Client) ReadTemplate(uuid string) (*TemplateApiDTO, error) {
+// by the supplied UUID or an error if encountered. If the template does not
+// exist, the error satisfies IsNotFound.
+func (c *Client) ReadTemplate(ctx context.Context, uuid string) (*TemplateApiDTO, error) {
 	log.Tracef("turbonomic/api/templates.go#ReadTemplate")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s", TemplatesPrefix, uuid)
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodGet,
 		reqEndpoint,
 		nil,
@@ -183,7 +191,16 @@ func (c *Client) ReadTemplate(uuid string) (*TemplateApiDTO, error) {
 
 	lenReadObjs := len(readObjs)
 	if lenReadObjs == 0 {
-		return nil, fmt.Errorf("Could not find template by UUID [%s]", uuid)
+		// Some Turbonomic versions respond with an empty list rather than a
+		// 404 for unknown UUIDs.  Report both the same way.
+		return nil, &APIError{
+			StatusCode: http.StatusNotFound,
+			Method:     req.Method,
+			Endpoint:   req.URL.String(),
+			Response: ErrorApiDTO{
+				Message: fmt.Sprintf("Could not find template by UUID [%s]", uuid),
+			},
+		}
 	} else if lenReadObjs > 1 {
 		return nil, fmt.Errorf("Matched multiple templates by UUID [%s]", uuid)
 	}
@@ -195,7 +212,7 @@ func (c *Client) ReadTemplate(uuid string) (*TemplateApiDTO, error) {
 // The properties of the template will be set the values of the supplied
 // TemplateApiInputDTO. This function returns a reference to the template with
 // the updated properties or an error if encountered.
-func (c *Client) UpdateTemplate(uuid string, obj *TemplateApiInputDTO) (*TemplateApiDTO, error) {
+func (c *Client) UpdateTemplate(ctx context.Context, uuid string, obj *TemplateApiInputDTO) (*TemplateApiDTO, error) {
 	log.Tracef("turbonomic/api/templates.go#UpdateTemplate")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s", TemplatesPrefix, uuid)
@@ -206,6 +223,7 @@ func (c *Client) UpdateTemplate(uuid string, obj *TemplateApiInputDTO) (*Templat
 	}
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodPut,
 		reqEndpoint,
 		bytes.NewBuffer(objJSONBytes),
@@ -225,12 +243,13 @@ func (c *Client) UpdateTemplate(uuid string, obj *TemplateApiInputDTO) (*Templat
 
 // DeleteTemplate deletes the Turbonomic template identitied by the given
 // UUID. It will return an error if encountered.
-func (c *Client) DeleteTemplate(uuid string) error {
+func (c *Client) DeleteTemplate(ctx context.Context, uuid string) error {
 	log.Tracef("turbonomic/api/templates.go#DeleteTemplate")
 
 	reqEndpoint := fmt.Sprintf("/%s/%s", TemplatesPrefix, uuid)
 
 	req, reqErr := c.NewRequest(
+		ctx,
 		http.MethodDelete,
 		reqEndpoint,
 		nil,
@@ -243,26 +262,47 @@ func (c *Client) DeleteTemplate(uuid string) error {
 }
 
 // Templates returns all Template objects in Turbonomic or an error
-// if one encountered.
-func (c *Client) Templates() ([]TemplateApiDTO, error) {
+// if one encountered. All pages of the list are read.
+func (c *Client) Templates(ctx context.Context) ([]TemplateApiDTO, error) {
 	log.Tracef("turbonomic/api/templates.go#Templates")
 
 	reqEndpoint := fmt.Sprintf("/%s", TemplatesPrefix)
 
-	req, reqErr := c.NewRequest(
-		http.MethodGet,
-		reqEndpoint,
-		nil,
-	)
-	if reqErr != nil {
-		return nil, reqErr
+	var templates []TemplateApiDTO
+	listErr := c.ListAll(ctx, reqEndpoint, nil, &templates)
+	if listErr != nil {
+		return nil, listErr
 	}
 
-	var templates []TemplateApiDTO
-	sendErr := c.SendAndParse(req, &templates)
-	if sendErr != nil {
-		return nil, sendErr
+	return templates, nil
+}
+
+// TemplateSearchTypes returns the search types of the templates of every
+// ClassNameXxx class, ie: "VirtualMachineProfile"
+func TemplateSearchTypes() []string {
+	return []string{
+		ClassNameContainer + "Profile",
+		ClassNamePhysicalMachine + "Profile",
+		ClassNameStorage + "Profile",
+		ClassNameVirtualMachine + "Profile",
 	}
+}
 
+// SearchTemplates returns the templates matching the query or an error if
+// one encountered.  The query searches the templates of every class unless
+// its Types are set.  Search results may omit the resources of the
+// templates: use ReadTemplate for the complete template.
+func (c *Client) SearchTemplates(ctx context.Context, query SearchQuery) ([]TemplateApiDTO, error) {
+	log.Tracef("turbonomic/api/templates.go#SearchTemplates")
+
+	if len(query.Types) == 0 {
+		query.Types = TemplateSearchTypes()
+	}
+
+	var templates []TemplateApiDTO
+	searchErr := c.Search(ctx, query, &templates)
+	if searchErr != nil {
+		return nil, searchErr
+	}
 	return templates, nil
 }
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+// TLSVersions maps the TLS versions accepted by TLSOptions.MinVersion to
+// their crypto/tls identifiers
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// pemBlockPrefix - marker identifying inline PEM content as opposed to a path
+// to a PEM file
+const pemBlockPrefix = "-----BEGIN"
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// TLSOptions configures how the client verifies the Turbonomic server and
+// authenticates itself at the TLS layer.  Certificates and keys are given
+// either as a path to a PEM file or as inline PEM content.
+type TLSOptions struct {
+	// CA certificates trusted to sign the server's certificate, in addition
+	// to the system roots.  Optional.
+	CABundle string
+	// Client certificate and private key presented to the server for mutual
+	// TLS.  Either both or neither must be set.
+	ClientCert string
+	ClientKey  string
+	// Minimum TLS version negotiated with the server, ie: "1.2".  See
+	// TLSVersions for the accepted values.  Defaults to the crypto/tls
+	// default.
+	MinVersion string
+	// Name used to verify the server's certificate and sent as SNI, when it
+	// differs from the hostname the client connects to.  Optional.
+	ServerName string
+}
+
+// Validate returns an error if the options cannot be turned into a TLS
+// configuration, ie: an unreadable CA bundle or a certificate without a key.
+func (options TLSOptions) Validate() error {
+	_, tlsErr := options.tlsConfig(false)
+	return tlsErr
+}
+
+// tlsConfig builds the TLS configuration of the client's HTTP transport.
+func (options TLSOptions) tlsConfig(insecure bool) (*tls.Config, error) {
+	log.Tracef("turbonomic/api/tls.go#tlsConfig")
+
+	cfg := &tls.Config{
+		InsecureSkipVerify: insecure,
+		ServerName:         options.ServerName,
+	}
+
+	if options.MinVersion != "" {
+		version, ok := TLSVersions[options.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf(
+				"Unsupported TLS minimum version [%s], expected one of %v",
+				options.MinVersion,
+				TLSVersionNames(),
+			)
+		}
+		cfg.MinVersion = version
+	}
+
+	if options.CABundle != "" {
+		caPEM, readErr := readPEM(options.CABundle)
+		if readErr != nil {
+			return nil, fmt.Errorf("Unable to read the CA bundle: %s", readErr)
+		}
+		// Trust the system roots as well, so that the bundle only needs to
+		// contain the internal CAs.  SystemCertPool is unavailable on some
+		// platforms, in which case only the bundle is trusted.
+		pool, poolErr := x509.SystemCertPool()
+		if poolErr != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("The CA bundle does not contain any PEM certificate")
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (options.ClientCert == "") != (options.ClientKey == "") {
+		return nil, fmt.Errorf("Both a client certificate and a client key are required for mutual TLS")
+	}
+	if options.ClientCert != "" {
+		certPEM, certErr := readPEM(options.ClientCert)
+		if certErr != nil {
+			return nil, fmt.Errorf("Unable to read the client certificate: %s", certErr)
+		}
+		keyPEM, keyErr := readPEM(options.ClientKey)
+		if keyErr != nil {
+			return nil, fmt.Errorf("Unable to read the client key: %s", keyErr)
+		}
+		cert, pairErr := tls.X509KeyPair(certPEM, keyPEM)
+		if pairErr != nil {
+			return nil, fmt.Errorf("Invalid client certificate or key: %s", pairErr)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// readPEM returns the given value if it is inline PEM content, or the
+// content of the file it names otherwise.
+func readPEM(value string) ([]byte, error) {
+	if strings.Contains(value, pemBlockPrefix) {
+		return []byte(value), nil
+	}
+	return ioutil.ReadFile(value)
+}
+
+// TLSVersionNames returns the sorted list of TLS versions accepted by
+// TLSOptions.MinVersion
+func TLSVersionNames() []string {
+	names := make([]string, 0, len(TLSVersions))
+	for name := range TLSVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
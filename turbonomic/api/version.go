@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	log "github.com/foo/terraform-provider-utils/log"
+)
+
+const (
+	// VersionsPrefix - API endpoint reporting the product version of the
+	// Turbonomic server
+	VersionsPrefix = "admin/versions"
+	// xlMajorVersion - first major version of Turbonomic XL.  Earlier
+	// versions are Turbonomic classic.
+	xlMajorVersion = 7
+)
+
+var (
+	// versionPattern - matches the dotted version in the versionInfo string,
+	// ie: "Turbonomic Operations Manager 8.2.0 (Build ...)"
+	versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+	// buildPattern - matches the build in the versionInfo string, ie:
+	// `Build "20210602130022000"`
+	buildPattern = regexp.MustCompile(`Build "?([^")\s]+)`)
+)
+
+// -----------------------------------------------------------------------------
+// Struct Definition and Helpers
+// -----------------------------------------------------------------------------
+
+// ProductVersionDTO - version information returned by /admin/versions
+type ProductVersionDTO struct {
+	// Human readable product version and build, ie:
+	// "Turbonomic Operations Manager 8.2.0 (Build \"20210602130022000\")"
+	VersionInfo string `json:"versionInfo,omitempty"`
+	// Product version, ie: "8.2.0".  Only reported by some versions, parsed
+	// from VersionInfo otherwise.
+	Version string `json:"version,omitempty"`
+	// Product build.  Only reported by some versions, parsed from
+	// VersionInfo otherwise.
+	Build string `json:"build,omitempty"`
+	// Available updates, if any
+	Updates string `json:"updates,omitempty"`
+	// Version of the market analysis engine
+	MarketVersion int `json:"marketVersion,omitempty"`
+}
+
+// ServerVersion - product version of the Turbonomic server the client is
+// connected to
+type ServerVersion struct {
+	Major int
+	Minor int
+	Patch int
+	// Build identifier, may be empty
+	Build string
+	// Version information as reported by the server
+	VersionInfo string
+}
+
+// IsXL reports whether the server runs Turbonomic XL (7.x and later) as
+// opposed to Turbonomic classic (6.x and earlier).
+func (v ServerVersion) IsXL() bool {
+	return v.Major >= xlMajorVersion
+}
+
+// String returns the dotted version and build, ie: "8.2.0 (build 20210602)"
+func (v ServerVersion) String() string {
+	version := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Build != "" {
+		version += fmt.Sprintf(" (build %s)", v.Build)
+	}
+	return version
+}
+
+// Capabilities - features of the Turbonomic API that differ between product
+// versions.  A client whose server version is unknown reports every
+// capability, so that requests are sent and validated by the server.
+type Capabilities struct {
+	// Whether list endpoints accept the `cursor` and `limit` query parameters
+	// and report the next page through the X-Next-Cursor header
+	CursorPagination bool
+	// Whether reservations accept a deployDateTime to deploy the reserved
+	// workloads at
+	ReservationDeployDateTime bool
+	// Whether reservations place highAvailability workloads on distinct
+	// hosts
+	ReservationHighAvailability bool
+	// Whether reservations accept a priority
+	ReservationPriority bool
+	// Whether reservations place geographicRedundancy workloads in distinct
+	// datacenters
+	ReservationGeographicRedundancy bool
+	// Template classes (ClassNameXxx) that can be created
+	TemplateClasses []string
+}
+
+// SupportsTemplateClass reports whether templates of the given class can be
+// created
+func (c Capabilities) SupportsTemplateClass(className string) bool {
+	for _, supported := range c.TemplateClasses {
+		if supported == className {
+			return true
+		}
+	}
+	return false
+}
+
+// allCapabilities - capabilities reported while the server version is
+// unknown
+var allCapabilities = Capabilities{
+	CursorPagination:                true,
+	ReservationDeployDateTime:       true,
+	ReservationHighAvailability:     true,
+	ReservationPriority:             true,
+	ReservationGeographicRedundancy: true,
+	TemplateClasses: []string{
+		ClassNameContainer,
+		ClassNamePhysicalMachine,
+		ClassNameStorage,
+		ClassNameVirtualMachine,
+	},
+}
+
+// capabilitiesOf returns the capabilities of the given server version
+func capabilitiesOf(version ServerVersion) Capabilities {
+	if !version.IsXL() {
+		// Turbonomic classic lists every object in a single response
+		return Capabilities{
+			CursorPagination:                false,
+			ReservationDeployDateTime:       true,
+			ReservationHighAvailability:     true,
+			ReservationPriority:             true,
+			ReservationGeographicRedundancy: true,
+			TemplateClasses:                 allCapabilities.TemplateClasses,
+		}
+	}
+	// Turbonomic XL no longer deploys reserved workloads nor manages
+	// container templates.  Its reservations are placed in a plan market
+	// that keeps highly available workloads apart but has no notion of
+	// priority nor of geographic redundancy.
+	return Capabilities{
+		CursorPagination:                true,
+		ReservationDeployDateTime:       false,
+		ReservationHighAvailability:     true,
+		ReservationPriority:             false,
+		ReservationGeographicRedundancy: false,
+		TemplateClasses: []string{
+			ClassNamePhysicalMachine,
+			ClassNameStorage,
+			ClassNameVirtualMachine,
+		},
+	}
+}
+
+// versionFormatError is returned when the /admin/versions response does not
+// contain a version
+type versionFormatError struct {
+	raw string
+}
+
+// Error implements the error interface
+func (e *versionFormatError) Error() string {
+	return fmt.Sprintf("Unable to parse the Turbonomic version from [%s]", e.raw)
+}
+
+// parseServerVersion extracts the server version from the /admin/versions
+// response
+func parseServerVersion(dto *ProductVersionDTO) (ServerVersion, error) {
+	version := ServerVersion{
+		Build:       dto.Build,
+		VersionInfo: dto.VersionInfo,
+	}
+
+	raw := dto.Version
+	if raw == "" {
+		raw = dto.VersionInfo
+	}
+	match := versionPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return version, &versionFormatError{raw: raw}
+	}
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		version.Patch, _ = strconv.Atoi(match[3])
+	}
+
+	if version.Build == "" {
+		if buildMatch := buildPattern.FindStringSubmatch(dto.VersionInfo); buildMatch != nil {
+			version.Build = buildMatch[1]
+		}
+	}
+	return version, nil
+}
+
+// -----------------------------------------------------------------------------
+// Client Integration
+// -----------------------------------------------------------------------------
+
+// DetectVersion queries the server's product version and records it, along
+// with the corresponding capabilities, on the client.
+func (client *Client) DetectVersion(ctx context.Context) (*ServerVersion, error) {
+	log.Tracef("turbonomic/api/version.go#DetectVersion")
+
+	req, reqErr := client.NewRequest(
+		ctx,
+		http.MethodGet,
+		VersionsPrefix,
+		nil,
+	)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	dto := ProductVersionDTO{}
+	if sendErr := client.SendAndParse(req, &dto); sendErr != nil {
+		return nil, sendErr
+	}
+
+	version, parseErr := parseServerVersion(&dto)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	log.Infof("Turbonomic server version: [%s]", version.String())
+
+	client.versionMutex.Lock()
+	defer client.versionMutex.Unlock()
+	client.version = &version
+	return &version, nil
+}
+
+// Version returns the server version recorded by DetectVersion.  The
+// boolean is false if the version has not been detected.
+func (client *Client) Version() (ServerVersion, bool) {
+	client.versionMutex.RLock()
+	defer client.versionMutex.RUnlock()
+	if client.version == nil {
+		return ServerVersion{}, false
+	}
+	return *client.version, true
+}
+
+// Capabilities returns the capabilities of the server.  Every capability is
+// reported if the version has not been detected.
+func (client *Client) Capabilities() Capabilities {
+	version, known := client.Version()
+	if !known {
+		return allCapabilities
+	}
+	return capabilitiesOf(version)
+}
+
+// -----------------------------------------------------------------------------
+// Unsupported Features
+// -----------------------------------------------------------------------------
+
+// UnsupportedError is returned when a request relies on a feature the
+// Turbonomic server does not support
+type UnsupportedError struct {
+	// Description of the unsupported feature, ie: "reservation deploy time"
+	Feature string
+	// Version of the server
+	Version ServerVersion
+}
+
+// Error implements the error interface
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf(
+		"%s is not supported on this Turbonomic version [%s]",
+		e.Feature,
+		e.Version.String(),
+	)
+}
+
+// IsUnsupported reports whether err indicates that the server does not
+// support the requested feature.
+func IsUnsupported(err error) bool {
+	_, ok := err.(*UnsupportedError)
+	return ok
+}
+
+// unsupported returns an UnsupportedError for the given feature on the
+// client's server version
+func (client *Client) unsupported(feature string) error {
+	version, _ := client.Version()
+	return &UnsupportedError{Feature: feature, Version: version}
+}
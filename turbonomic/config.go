@@ -1,12 +1,19 @@
 package turbonomic
 
 import (
+	"context"
+	"io"
 	"net/url"
+	"time"
 
 	log "github.com/foo/terraform-provider-utils/log"
 	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
 )
 
+// probeTimeout - upper bound for the connectivity and credentials check made
+// when the provider is configured
+const probeTimeout = 30 * time.Second
+
 // Config struct defines the necessary information needed to configure the
 // terraform provider for communication with the Turbonomic API.
 type Config struct {
@@ -14,26 +21,112 @@ type Config struct {
 	// API requests to Turbonomic.  This is constructed from the hostname, port, and
 	// protocol options passed to the provider.
 	Server url.URL
+	// Path under which the Turbonomic API is served, ie: "/api/v2"
+	APIBasePath string
+	// Context canceled when Terraform interrupts the provider.  Every request
+	// issued by the REST client is aborted once it is done.
+	StopContext context.Context
 	// Whether or not to verify the server's certificate/hostname.  This flag
 	// is passed to the TLS config when initializing the REST client for API
 	// communication.
 	//
 	// See 'pkg/crypto/tls/#Config.InsecureSkipVerify' for more information.
 	ClientTLSInsecure bool
+	// CA bundle, client certificate and TLS settings used to connect to
+	// Turbonomic
+	ClientTLS api.TLSOptions
+	// Proxy every request is sent through.  Nil to use the proxy configured
+	// in the environment.
+	HTTPProxy *url.URL
+	// Maximum duration of a single HTTP request.  Zero means no timeout.
+	RequestTimeout time.Duration
+	// Maximum duration to establish a connection to Turbonomic
+	DialTimeout time.Duration
 	// Set of credentials needed to authenticate against Turbonomic
 	ClientCredentials api.ClientCredentials
+	// Whether or not to skip the connectivity and credentials check made when
+	// the client is created
+	SkipCredentialsValidation bool
+	// Maximum number of times a request is retried after a transient failure
+	MaxRetries int
+	// Upper bound for the wait between retries
+	RetryMaxWait time.Duration
+	// Average number of requests per second sent to Turbonomic.  Zero
+	// disables rate limiting.
+	MaxRequestsPerSecond float64
+	// Maximum number of requests in flight at once.  Zero means unlimited.
+	MaxConcurrentRequests int
+	// How long successful GET responses are cached.  Zero disables the cache.
+	ResponseCacheTTL time.Duration
+	// Format of the entries logged for every request, one of the
+	// api.LogFormatXxx constants
+	LogFormat string
+	// Writer the api.LogFormatJSON entries are written to
+	LogOutput io.Writer
+	// Maximum number of bytes of each request and response body logged.
+	// Zero omits the bodies.
+	LogMaxBodySize int
+	// Records the requests made by the client.  Optional.
+	Metrics *api.Metrics
 }
 
 // Creates a client reference for the Turbonomic REST API given the provider
-// configuration options.  After creating a client reference, the client
-// is then authenticated with the credentials supplied to the provider
-// configuration.
+// configuration options.  The client authenticates with the credentials
+// supplied to the provider configuration when it sends its first request.
 func (c *Config) Client() (*api.Client, error) {
 	log.Tracef("config.go#Client")
 
-	client := api.NewClient(c.Server, c.ClientTLSInsecure, c.ClientCredentials)
+	var middlewares []api.Middleware
+	if c.Metrics != nil {
+		middlewares = append(middlewares, c.Metrics.Middleware())
+	}
+
+	client := api.NewClient(
+		c.Server,
+		c.ClientTLSInsecure,
+		c.ClientCredentials,
+		api.ClientOptions{
+			MaxRetries:   c.MaxRetries,
+			RetryMaxWait: c.RetryMaxWait,
+			// Allow a burst of one second's worth of requests
+			MaxRequestsPerSecond:  c.MaxRequestsPerSecond,
+			RequestBurst:          int(c.MaxRequestsPerSecond),
+			MaxConcurrentRequests: c.MaxConcurrentRequests,
+			CacheTTL:              c.ResponseCacheTTL,
+			StopContext:           c.StopContext,
+			TLS:                   c.ClientTLS,
+			APIBasePath:           c.APIBasePath,
+			Proxy:                 c.HTTPProxy,
+			RequestTimeout:        c.RequestTimeout,
+			DialTimeout:           c.DialTimeout,
+			LogFormat:             c.LogFormat,
+			LogOutput:             c.LogOutput,
+			LogMaxBodySize:        c.LogMaxBodySize,
+			Middlewares:           middlewares,
+		},
+	)
 
 	log.Infof("Rest Client configured")
 
+	if c.SkipCredentialsValidation {
+		// Without the probe the server version is unknown: the client assumes
+		// every API capability and lets the server validate requests.
+		log.Infof("Skipping the Turbonomic credentials validation")
+		return client, nil
+	}
+
+	// Fail fast on a wrong hostname, certificate or password rather than on
+	// the first resource read.  The probe also records the server version
+	// so that resources can check the API capabilities.
+	ctx := c.StopContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	if _, probeErr := client.Probe(probeCtx); probeErr != nil {
+		return nil, probeErr
+	}
+
 	return client, nil
 }
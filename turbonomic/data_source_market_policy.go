@@ -51,7 +51,11 @@ func dataSourceTurboMarketPolicyRead(d *schema.ResourceData, meta interface{}) e
 
 	client := meta.(*api.Client)
 
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
 	readPolicy, readErr := client.ReadMarketPolicy(
+		ctx,
 		d.Get("display_name").(string),
 		d.Get("market_id").(string),
 	)
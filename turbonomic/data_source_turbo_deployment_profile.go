@@ -1,7 +1,6 @@
 package turbonomic
 
 import (
-	"encoding/json"
 	"fmt"
 
 	autodoc "github.com/foo/terraform-provider-utils/autodoc"
@@ -97,7 +96,14 @@ func dataSourceTurboDeploymentProfileRead(d *schema.ResourceData, meta interface
 
 	log.Debugf("DeploymentProfileApiDTO: [%+v]", obj)
 
-	queryObjs, queryErr := client.DeploymentProfiles()
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	// narrow down the profiles server-side by name, the search regex may be
+	// case insensitive so the names are compared again
+	queryObjs, queryErr := client.SearchDeploymentProfiles(ctx, api.SearchQuery{
+		NameRegex: api.ExactNameRegex(obj.DisplayName),
+	})
 	if queryErr != nil {
 		return queryErr
 	}
@@ -107,9 +113,7 @@ func dataSourceTurboDeploymentProfileRead(d *schema.ResourceData, meta interface
 	}
 
 	queryMatches := make([]api.DeploymentProfileApiDTO, 0)
-	for idx, queryObj := range queryObjs {
-		queryObjJSON, _ := json.MarshalIndent(queryObj, "", "  ")
-		log.Debugf("[%d] => [%s]", idx, queryObjJSON)
+	for _, queryObj := range queryObjs {
 		if queryObj.DisplayName == obj.DisplayName {
 			log.Debugf("  Matches!")
 			queryMatches = append(queryMatches, queryObj)
@@ -119,14 +123,13 @@ func dataSourceTurboDeploymentProfileRead(d *schema.ResourceData, meta interface
 	numQueryMatches := len(queryMatches)
 	log.Debugf("numQueryMatches: [%d]", numQueryMatches)
 	if numQueryMatches == 0 {
-		return fmt.Errorf("Found [%d] templates matching the search criteria", numQueryMatches)
+		return fmt.Errorf("Found [%d] deployment profiles matching the search criteria", numQueryMatches)
 	} else if numQueryMatches > 1 {
-		return fmt.Errorf("Found [%d] templates matching the search criteria", numQueryMatches)
+		return fmt.Errorf("Found [%d] deployment profiles matching the search criteria", numQueryMatches)
 	}
 
 	queryObj := &queryMatches[0]
-	queryObjJSON, _ := json.MarshalIndent(queryObj, "", "  ")
-	log.Debugf("Query DeploymentProfileApiDTO: [%s]", queryObjJSON)
+	log.Debugf("Query DeploymentProfileApiDTO: [%s] [%s]", queryObj.UUID, queryObj.DisplayName)
 
 	setResourceDataFromDeploymentProfile(d, queryObj)
 
@@ -0,0 +1,282 @@
+package turbonomic
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	autodoc "github.com/foo/terraform-provider-utils/autodoc"
+	log "github.com/foo/terraform-provider-utils/log"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceTurboEntity() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceTurboEntityRead,
+
+		Schema: map[string]*schema.Schema{
+
+			autodoc.MetaAttribute: &schema.Schema{
+				Type:     schema.TypeBool,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"%s Entities are the workloads and infrastructure managed by "+
+						"Turbonomic: VMs, hosts, datastores, etc.  Use this data "+
+						"source to look up an entity by name, ie: to reference the "+
+						"host or datastore a reservation was placed on.",
+					autodoc.MetaSummary,
+				),
+			},
+
+			// -- Searchable Attributes --
+			"class_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				Description: fmt.Sprintf(
+					"The type of the entity, ie: `VirtualMachine`, "+
+						"`PhysicalMachine` or `Storage`. "+
+						"%s \"PhysicalMachine\"",
+					autodoc.MetaExample,
+				),
+			},
+			"display_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				Description: fmt.Sprintf(
+					"The name of the entity. "+
+						"%s \"esx-bo1-042.foo.com\"",
+					autodoc.MetaExample,
+				),
+			},
+
+			// -- Computed Attributes --
+			"state": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"The state of the entity. "+
+						"%s \"ACTIVE\"",
+					autodoc.MetaExample,
+				),
+			},
+			"environment_type": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"The environment of the entity. "+
+						"%s \"ONPREM\"",
+					autodoc.MetaExample,
+				),
+			},
+			"targets": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Description: fmt.Sprintf(
+					"The names of the targets discovering the entity, the target "+
+						"that first discovered it first. "+
+						"%s [\"vcenter.host.foo.foo.com\"]",
+					autodoc.MetaExample,
+				),
+			},
+			"tags": &schema.Schema{
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Description: fmt.Sprintf(
+					"The tags of the entity.  Tags with multiple values have "+
+						"their values joined with commas. "+
+						"%s {\"owner\" = \"storage-team\"}",
+					autodoc.MetaExample,
+				),
+			},
+			"stats": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        dataSourceTurboEntityStat(),
+				Description: "The current commodity stats of the entity.",
+			},
+		},
+	}
+}
+
+// dataSourceTurboEntityStat defines the schema of a current commodity stat
+// of an entity.  This loosely translates to the `StatApiDTO`.
+func dataSourceTurboEntityStat() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Commodity name. "+
+						"%s `\"Mem\"`",
+					autodoc.MetaExample,
+				),
+			},
+			"units": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Commodity units. "+
+						"%s `\"KB\"`",
+					autodoc.MetaExample,
+				),
+			},
+			"value": &schema.Schema{
+				Type:     schema.TypeFloat,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Current value of the commodity. "+
+						"%s `16777216`",
+					autodoc.MetaExample,
+				),
+			},
+			"capacity": &schema.Schema{
+				Type:     schema.TypeFloat,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Capacity of the commodity, 0 when unbounded (see "+
+						"`capacity_unbounded`) or not reported. "+
+						"%s `33554432`",
+					autodoc.MetaExample,
+				),
+			},
+			"capacity_unbounded": &schema.Schema{
+				Type:     schema.TypeBool,
+				Computed: true,
+				Description: "Whether the commodity has an unbounded (infinite) " +
+					"capacity.",
+			},
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Conversion Helpers
+// -----------------------------------------------------------------------------
+
+// setResourceDataFromEntity takes a ServiceEntityApiDTO reference and its
+// current stats and writes back the state to the ResourceData reference
+func setResourceDataFromEntity(d *schema.ResourceData, obj *api.ServiceEntityApiDTO, stats []api.StatApiDTO) {
+	log.Tracef("setResourceDataFromEntity")
+	d.SetId(obj.UUID)
+	d.Set("class_name", obj.ClassName)
+	d.Set("display_name", obj.DisplayName)
+	d.Set("state", obj.State)
+	d.Set("environment_type", obj.EnvironmentType)
+	d.Set("targets", obj.DiscoveringTargets())
+
+	tags := map[string]interface{}{}
+	for name, values := range obj.Tags {
+		tags[name] = strings.Join(values, ",")
+	}
+	d.Set("tags", tags)
+
+	statList := make([]interface{}, len(stats))
+	for idx, stat := range stats {
+		statList[idx] = entityStatToMapstruct(stat)
+	}
+	d.Set("stats", statList)
+}
+
+// entityStatToMapstruct converts a current commodity StatApiDTO into a
+// map[string]interface{}.  Values that cannot be stored in the state
+// (infinities and NaN) are written as 0.
+func entityStatToMapstruct(obj api.StatApiDTO) map[string]interface{} {
+	stat := map[string]interface{}{
+		"name":  obj.Name,
+		"units": obj.Units,
+		"value": finiteStatValue(obj.Value),
+	}
+	setStatCapacity(stat, obj.Capacity)
+	return stat
+}
+
+// setStatCapacity adds the capacity of a stat to its mapstruct.  An infinite
+// capacity cannot be stored in the state: it is written as 0, as it is when
+// not reported, and flagged as unbounded.
+func setStatCapacity(stat map[string]interface{}, capacity *api.StatValueApiDTO) {
+	stat["capacity"] = 0.0
+	stat["capacity_unbounded"] = false
+	if capacity == nil {
+		return
+	}
+	total := float64(capacity.Total)
+	switch {
+	case math.IsInf(total, 1):
+		stat["capacity_unbounded"] = true
+	case !math.IsInf(total, -1) && !math.IsNaN(total):
+		stat["capacity"] = total
+	}
+}
+
+// finiteStatValue returns the stat value, or 0 for infinities and NaN
+func finiteStatValue(value api.StatFloat) float64 {
+	if math.IsInf(float64(value), 0) || math.IsNaN(float64(value)) {
+		return 0
+	}
+	return float64(value)
+}
+
+// -----------------------------------------------------------------------------
+// CRUD Functions
+// -----------------------------------------------------------------------------
+
+func dataSourceTurboEntityRead(d *schema.ResourceData, meta interface{}) error {
+	log.Tracef("data_source_turbo_entity.go#Read")
+
+	client := meta.(*api.Client)
+
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	className := d.Get("class_name").(string)
+	displayName := d.Get("display_name").(string)
+
+	// the search regex may be case insensitive so the names are compared
+	// again
+	queryObjs, queryErr := client.SearchEntities(ctx, api.SearchQuery{
+		NameRegex: api.ExactNameRegex(displayName),
+		Types:     []string{className},
+	})
+	if queryErr != nil {
+		return queryErr
+	}
+
+	queryMatches := make([]api.ServiceEntityApiDTO, 0)
+	for _, queryObj := range queryObjs {
+		if queryObj.DisplayName == displayName {
+			queryMatches = append(queryMatches, queryObj)
+		}
+	}
+
+	numQueryMatches := len(queryMatches)
+	log.Debugf("numQueryMatches: [%d]", numQueryMatches)
+	if numQueryMatches != 1 {
+		return fmt.Errorf(
+			"Found [%d] entities of class [%s] named [%s]",
+			numQueryMatches,
+			className,
+			displayName,
+		)
+	}
+
+	// search results may omit the tags and targets
+	readObj, readErr := client.ReadEntity(ctx, queryMatches[0].UUID)
+	if readErr != nil {
+		return readErr
+	}
+	log.Debugf("Read ServiceEntityApiDTO: [%s] [%s]", readObj.UUID, readObj.DisplayName)
+
+	stats, statsErr := client.CurrentEntityStats(ctx, readObj.UUID)
+	if statsErr != nil {
+		return statsErr
+	}
+
+	setResourceDataFromEntity(d, readObj, stats)
+
+	return nil
+}
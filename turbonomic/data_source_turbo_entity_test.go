@@ -0,0 +1,61 @@
+package turbonomic
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+func TestAccDataSourceTurboEntity_basic(t *testing.T) {
+	server := testAccFakeServer(t)
+	hostID := server.AddEntity(api.ServiceEntityApiDTO{
+		ClassName:       api.ClassNamePhysicalMachine,
+		DisplayName:     "esx-acc-01",
+		EnvironmentType: "ONPREM",
+		DiscoveredBy:    &api.TargetApiDTO{DisplayName: "vcenter-b.fake.local"},
+		VendorIds: map[string]string{
+			"vcenter-a.fake.local": "host-1",
+			"vcenter-b.fake.local": "host-1",
+		},
+		Tags: map[string][]string{"owner": {"compute", "storage"}},
+	}, []api.StatApiDTO{
+		{Name: "Mem", Units: "KB", Value: 1024, Capacity: &api.StatValueApiDTO{Total: 4096}},
+		{Name: "Q1VCPU", Units: "msec", Value: 2, Capacity: &api.StatValueApiDTO{Total: api.StatFloat(math.Inf(1))}},
+	})
+	// same name, different class
+	server.AddEntity(api.ServiceEntityApiDTO{
+		ClassName:   api.ClassNameVirtualMachine,
+		DisplayName: "esx-acc-01",
+	}, nil)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "turbonomic_entity" "host" {
+  class_name   = "PhysicalMachine"
+  display_name = "esx-acc-01"
+}
+`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "id", hostID),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "state", "ACTIVE"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "environment_type", "ONPREM"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "targets.#", "2"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "targets.0", "vcenter-b.fake.local"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "targets.1", "vcenter-a.fake.local"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "tags.owner", "compute,storage"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "stats.#", "2"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "stats.0.name", "Mem"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "stats.0.capacity", "4096"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "stats.0.capacity_unbounded", "false"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "stats.1.capacity", "0"),
+					resource.TestCheckResourceAttr("data.turbonomic_entity.host", "stats.1.capacity_unbounded", "true"),
+				),
+			},
+		},
+	})
+}
@@ -1,7 +1,6 @@
 package turbonomic
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
@@ -44,13 +43,15 @@ func dataSourceTurboMarketRead(d *schema.ResourceData, meta interface{}) error {
 
 	client := meta.(*api.Client)
 
-	readMarket, readErr := client.ReadMarket(d.Get("display_name").(string))
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	readMarket, readErr := client.ReadMarket(ctx, d.Get("display_name").(string))
 	if readErr != nil {
 		return readErr
 	}
 
-	mktObjJSON, _ := json.MarshalIndent(readMarket, "", "  ")
-	log.Debugf("Read Market: [%s]", mktObjJSON)
+	log.Debugf("Read Market: [%s] [%s]", readMarket.UUID, readMarket.DisplayName)
 
 	setResourceDataFromMarket(d, readMarket)
 
@@ -0,0 +1,336 @@
+package turbonomic
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	autodoc "github.com/foo/terraform-provider-utils/autodoc"
+	log "github.com/foo/terraform-provider-utils/log"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
+)
+
+const (
+	// Rollups of the turbonomic_stats data source
+	StatsRollupNone = "none"
+	StatsRollupHour = "hour"
+	StatsRollupDay  = "day"
+	StatsRollupWeek = "week"
+	StatsRollupAll  = "all"
+)
+
+// statsRollupIntervals - intervals of the stats rollups.  StatsRollupAll
+// aggregates the whole period.
+var statsRollupIntervals = map[string]time.Duration{
+	StatsRollupHour: time.Hour,
+	StatsRollupDay:  24 * time.Hour,
+	StatsRollupWeek: 7 * 24 * time.Hour,
+	StatsRollupAll:  0,
+}
+
+func dataSourceTurboStats() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceTurboStatsRead,
+
+		Schema: map[string]*schema.Schema{
+
+			autodoc.MetaAttribute: &schema.Schema{
+				Type:     schema.TypeBool,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"%s Historical, current and projected stats of an entity, a "+
+						"group (ie: a cluster) or a market.  Periods in the past "+
+						"return historical stats, periods in the future return "+
+						"projected stats, and omitting the period returns the "+
+						"current stats.",
+					autodoc.MetaSummary,
+				),
+			},
+
+			// -- Searchable Attributes --
+			"scope": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				Description: fmt.Sprintf(
+					"UUID of the entity, group or market. "+
+						"%s \"${data.turbonomic_entity.host.id}\"",
+					autodoc.MetaExample,
+				),
+			},
+			"stat_names": &schema.Schema{
+				Type:     schema.TypeList,
+				Required: true,
+				MinItems: 1,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Description: fmt.Sprintf(
+					"Names of the stats to read. "+
+						"%s [\"CPU\", \"Mem\", \"StorageAmount\"]",
+					autodoc.MetaExample,
+				),
+			},
+			"related_entity_type": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: fmt.Sprintf(
+					"Type of the entities in the scope the stats are read "+
+						"from, ie: the hosts of a cluster. "+
+						"%s \"PhysicalMachine\"",
+					autodoc.MetaExample,
+				),
+			},
+			"start_date": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateStatDate,
+				Description: fmt.Sprintf(
+					"Start of the period: `now`, an offset from now in hours, "+
+						"days or weeks, an RFC 3339 timestamp, or a `YYYY-MM-DD` "+
+						"date (UTC).  Defaults to `now` when `end_date` is set. "+
+						"%s \"-7d\"",
+					autodoc.MetaExample,
+				),
+			},
+			"end_date": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateStatDate,
+				Description: fmt.Sprintf(
+					"End of the period, in the same formats as `start_date`. "+
+						"Defaults to `now` when `start_date` is set. "+
+						"%s \"+1d\"",
+					autodoc.MetaExample,
+				),
+			},
+			"rollup": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  StatsRollupNone,
+				ValidateFunc: validation.StringInSlice([]string{
+					StatsRollupNone,
+					StatsRollupHour,
+					StatsRollupDay,
+					StatsRollupWeek,
+					StatsRollupAll,
+				}, false),
+				Description: fmt.Sprintf(
+					"Aggregates the snapshots returned by Turbonomic per hour, "+
+						"day or week (UTC), or over the whole period with `all`. "+
+						"Historical and projected stats are aggregated "+
+						"separately.  Ignored when reading the current stats. "+
+						"DEFAULT: `%s`",
+					StatsRollupNone,
+				),
+			},
+
+			// -- Computed Attributes --
+			"stats": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        dataSourceTurboStatsStat(),
+				Description: "Time-stamped stats, oldest first.",
+			},
+		},
+	}
+}
+
+// dataSourceTurboStatsStat defines the schema of a time-stamped stat.  This
+// loosely translates to a `StatApiDTO` and the date and epoch of its
+// `StatSnapshotApiDTO`.
+func dataSourceTurboStatsStat() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"date": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Date of the stat. "+
+						"%s `\"2021-06-02T13:00:00Z\"`",
+					autodoc.MetaExample,
+				),
+			},
+			"epoch": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Whether the stat is `HISTORICAL`, `CURRENT` or "+
+						"`PROJECTED`. "+
+						"%s `\"HISTORICAL\"`",
+					autodoc.MetaExample,
+				),
+			},
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Stat name. "+
+						"%s `\"CPU\"`",
+					autodoc.MetaExample,
+				),
+			},
+			"units": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Stat units. "+
+						"%s `\"MHz\"`",
+					autodoc.MetaExample,
+				),
+			},
+			"avg": &schema.Schema{
+				Type:        schema.TypeFloat,
+				Computed:    true,
+				Description: "Average value of the stat.",
+			},
+			"max": &schema.Schema{
+				Type:        schema.TypeFloat,
+				Computed:    true,
+				Description: "Peak value of the stat.",
+			},
+			"min": &schema.Schema{
+				Type:        schema.TypeFloat,
+				Computed:    true,
+				Description: "Minimum value of the stat.",
+			},
+			"capacity": &schema.Schema{
+				Type:     schema.TypeFloat,
+				Computed: true,
+				Description: "Capacity of the stat, 0 when unbounded (see " +
+					"`capacity_unbounded`) or not reported.",
+			},
+			"capacity_unbounded": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the stat has an unbounded (infinite) capacity.",
+			},
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Data Source Helpers and Validation
+// -----------------------------------------------------------------------------
+
+// validateStatDate validates the format of a stat date
+func validateStatDate(v interface{}, k string) ([]string, []error) {
+	if _, parseErr := api.ParseStatDate(v.(string), time.Now()); parseErr != nil {
+		return nil, []error{fmt.Errorf("%s: %s", k, parseErr)}
+	}
+	return nil, nil
+}
+
+// buildStatPeriod constructs a concrete StatPeriodApiInputDTO struct from the
+// ResourceData reference.  Dates are resolved relative to now.
+func buildStatPeriod(d *schema.ResourceData, now time.Time) (*api.StatPeriodApiInputDTO, error) {
+	log.Tracef("buildStatPeriod")
+
+	period := api.StatPeriodApiInputDTO{}
+	relatedEntityType := d.Get("related_entity_type").(string)
+	for _, name := range d.Get("stat_names").([]interface{}) {
+		period.Statistics = append(period.Statistics, api.StatApiInputDTO{
+			Name:              name.(string),
+			RelatedEntityType: relatedEntityType,
+		})
+	}
+
+	startDate := d.Get("start_date").(string)
+	endDate := d.Get("end_date").(string)
+	if startDate == "" && endDate == "" {
+		return &period, nil
+	}
+	if startDate == "" {
+		startDate = api.StatDateNow
+	}
+	if endDate == "" {
+		endDate = api.StatDateNow
+	}
+	start, parseErr := api.ParseStatDate(startDate, now)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	end, parseErr := api.ParseStatDate(endDate, now)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("Stats end date [%s] is before the start date [%s]", endDate, startDate)
+	}
+	period.StartDate = api.FormatStatDate(start)
+	period.EndDate = api.FormatStatDate(end)
+	return &period, nil
+}
+
+// statSnapshotsToList flattens the snapshots into the list of time-stamped
+// stats written to the state.  Values that cannot be stored in the state
+// (infinities and NaN) are written as 0, and infinite capacities flagged as
+// unbounded.
+func statSnapshotsToList(snapshots []api.StatSnapshotApiDTO) []interface{} {
+	stats := []interface{}{}
+	for _, snapshot := range snapshots {
+		for _, stat := range snapshot.Statistics {
+			values := stat.Values
+			if values == nil {
+				values = &api.StatValueApiDTO{Avg: stat.Value, Max: stat.Value, Min: stat.Value}
+			}
+			flattened := map[string]interface{}{
+				"date":  snapshot.Date,
+				"epoch": snapshot.Epoch,
+				"name":  stat.Name,
+				"units": stat.Units,
+				"avg":   finiteStatValue(values.Avg),
+				"max":   finiteStatValue(values.Max),
+				"min":   finiteStatValue(values.Min),
+			}
+			setStatCapacity(flattened, stat.Capacity)
+			stats = append(stats, flattened)
+		}
+	}
+	return stats
+}
+
+// -----------------------------------------------------------------------------
+// CRUD Functions
+// -----------------------------------------------------------------------------
+
+func dataSourceTurboStatsRead(d *schema.ResourceData, meta interface{}) error {
+	log.Tracef("data_source_turbo_stats.go#Read")
+
+	client := meta.(*api.Client)
+
+	period, periodErr := buildStatPeriod(d, time.Now())
+	if periodErr != nil {
+		return periodErr
+	}
+
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	scope := d.Get("scope").(string)
+	snapshots, statsErr := client.Stats(ctx, scope, period)
+	if statsErr != nil {
+		return statsErr
+	}
+	log.Debugf("Read [%d] stat snapshots of [%s]", len(snapshots), scope)
+
+	// the current stats have a single undated snapshot
+	rollup := d.Get("rollup").(string)
+	if period.StartDate != "" {
+		interval, ok := statsRollupIntervals[rollup]
+		if !ok {
+			// no aggregation, only the ordering
+			interval = -1
+		}
+		var rollupErr error
+		snapshots, rollupErr = api.RollupStatSnapshots(snapshots, interval)
+		if rollupErr != nil {
+			return rollupErr
+		}
+	}
+
+	d.SetId(strings.Join([]string{scope, period.StartDate, period.EndDate, rollup}, "/"))
+	d.Set("stats", statSnapshotsToList(snapshots))
+
+	return nil
+}
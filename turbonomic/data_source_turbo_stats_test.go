@@ -0,0 +1,96 @@
+package turbonomic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+)
+
+func TestAccDataSourceTurboStats_basic(t *testing.T) {
+	server := testAccFakeServer(t)
+	hostID := server.AddEntity(api.ServiceEntityApiDTO{
+		ClassName:   api.ClassNamePhysicalMachine,
+		DisplayName: "esx-acc-01",
+	}, []api.StatApiDTO{
+		{Name: "CPU", Units: "MHz", Value: 250, Capacity: &api.StatValueApiDTO{Total: 2000}},
+		{Name: "Mem", Units: "KB", Value: 1024},
+	})
+
+	// two historical snapshots on the same day, two days ago, and a projected
+	// one tomorrow
+	day := time.Now().UTC().Add(-48 * time.Hour).Truncate(24 * time.Hour)
+	snapshot := func(date time.Time, epoch string, avg, max api.StatFloat) api.StatSnapshotApiDTO {
+		return api.StatSnapshotApiDTO{
+			Date:  date.Format(time.RFC3339),
+			Epoch: epoch,
+			Statistics: []api.StatApiDTO{
+				{Name: "CPU", Units: "MHz", Values: &api.StatValueApiDTO{Avg: avg, Max: max, Min: avg}},
+				{Name: "Mem", Units: "KB", Values: &api.StatValueApiDTO{Avg: 1, Max: 1, Min: 1}},
+			},
+		}
+	}
+	// returned out of order
+	server.AddStatSnapshots(
+		hostID,
+		snapshot(day.Add(4*time.Hour), api.EpochHistorical, 300, 600),
+		snapshot(time.Now().Add(24*time.Hour), api.EpochProjected, 500, 500),
+		snapshot(day.Add(2*time.Hour), api.EpochHistorical, 100, 400),
+	)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + testAccTurboStatsConfig(hostID, `
+  start_date = "-7d"
+  end_date   = "+2d"
+  rollup     = "day"
+`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.#", "2"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.date", day.Format(time.RFC3339)),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.epoch", "HISTORICAL"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.avg", "200"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.max", "600"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.1.epoch", "PROJECTED"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.1.avg", "500"),
+				),
+			},
+			{
+				Config: testAccProviderConfig(server) + testAccTurboStatsConfig(hostID, `
+  start_date = "-7d"
+  end_date   = "+2d"
+`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.#", "3"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.date", day.Add(2*time.Hour).Format(time.RFC3339)),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.avg", "100"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.1.avg", "300"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.2.epoch", "PROJECTED"),
+				),
+			},
+			{
+				Config: testAccProviderConfig(server) + testAccTurboStatsConfig(hostID, ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.#", "1"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.epoch", "CURRENT"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.avg", "250"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.capacity", "2000"),
+					resource.TestCheckResourceAttr("data.turbonomic_stats.test", "stats.0.capacity_unbounded", "false"),
+				),
+			},
+		},
+	})
+}
+
+func testAccTurboStatsConfig(scope string, period string) string {
+	return fmt.Sprintf(`
+data "turbonomic_stats" "test" {
+  scope      = "%s"
+  stat_names = ["CPU"]
+%s}
+`, scope, period)
+}
@@ -1,7 +1,6 @@
 package turbonomic
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -61,14 +60,8 @@ func dataSourceTurboTemplateRead(d *schema.ResourceData, meta interface{}) error
 
 	client := meta.(*api.Client)
 
-	queryObjs, queryErr := client.Templates()
-	if queryErr != nil {
-		return queryErr
-	}
-	numQueryObjs := len(queryObjs)
-	if numQueryObjs == 0 {
-		return fmt.Errorf("Data source template returned 0 results")
-	}
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
 
 	displayName := d.Get("display_name").(string)
 	hasDeploymentProfile := d.Get("has_deployment_profile").(bool)
@@ -79,16 +72,34 @@ func dataSourceTurboTemplateRead(d *schema.ResourceData, meta interface{}) error
 		vcenter = ""
 	}
 
+	// narrow down the templates server-side by name, the search regex may be
+	// case insensitive so the names are compared again
+	queryObjs, queryErr := client.SearchTemplates(ctx, api.SearchQuery{
+		NameRegex: api.ExactNameRegex(displayName),
+	})
+	if queryErr != nil {
+		return queryErr
+	}
+	numQueryObjs := len(queryObjs)
+	if numQueryObjs == 0 {
+		return fmt.Errorf("Data source template returned 0 results")
+	}
+
 	queryMatches := make([]api.TemplateApiDTO, 0)
-	for idx, queryObj := range queryObjs {
-		queryObjJSON, _ := json.MarshalIndent(queryObj, "", "  ")
-		log.Debugf("[%d] => [%s]", idx, queryObjJSON)
-		if queryObj.DisplayName == displayName &&
-			((hasDeploymentProfile && queryObj.DeploymentProfile.UUID != "") ||
-				(!hasDeploymentProfile && queryObj.DeploymentProfile.UUID == "")) &&
+	for _, searchObj := range queryObjs {
+		if searchObj.DisplayName != displayName {
+			continue
+		}
+		// search results may omit the deployment profile and the model
+		queryObj, readErr := client.ReadTemplate(ctx, searchObj.UUID)
+		if readErr != nil {
+			return readErr
+		}
+		if ((hasDeploymentProfile && queryObj.DeploymentProfile.UUID != "") ||
+			(!hasDeploymentProfile && queryObj.DeploymentProfile.UUID == "")) &&
 			(vcenter != "" && strings.Contains(queryObj.Model, vcenter)) {
 			log.Debugf("  Matches!")
-			queryMatches = append(queryMatches, queryObj)
+			queryMatches = append(queryMatches, *queryObj)
 		}
 	}
 
@@ -101,8 +112,7 @@ func dataSourceTurboTemplateRead(d *schema.ResourceData, meta interface{}) error
 	}
 
 	queryObj := &queryMatches[0]
-	queryObjJSON, _ := json.MarshalIndent(queryObj, "", "  ")
-	log.Debugf("Query TemplateApiDTO: [%s]", queryObjJSON)
+	log.Debugf("Query TemplateApiDTO: [%s] [%s]", queryObj.UUID, queryObj.DisplayName)
 
 	setResourceDataFromTemplate(d, queryObj)
 
@@ -1,8 +1,17 @@
 package turbonomic
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
@@ -17,12 +26,62 @@ const (
 	ProviderLogLevelEnv string = "TURBO_PROVIDER_LOGLEVEL"
 	// Environment variable to configure the provider_logfile attribute
 	ProviderLogFileEnv string = "TURBO_PROVIDER_LOGFILE"
+	// Environment variable to configure the provider_log_format attribute
+	ProviderLogFormatEnv string = "TURBO_PROVIDER_LOG_FORMAT"
+	// Environment variable to configure the provider_log_max_body_size
+	// attribute
+	ProviderLogMaxBodySizeEnv string = "TURBO_PROVIDER_LOG_MAX_BODY_SIZE"
 	// Environment variable to configure the client_username attribute
 	ClientUsernameEnv string = "TURBO_CLIENT_USERNAME"
 	// Environment variable to configure the client_password attribute
 	ClientPasswordEnv string = "TURBO_CLIENT_PASSWORD"
+	// Environment variable to configure the client_auth_method attribute
+	ClientAuthMethodEnv string = "TURBO_CLIENT_AUTH_METHOD"
+	// Environment variable to configure the client_id attribute
+	ClientIDEnv string = "TURBO_CLIENT_ID"
+	// Environment variable to configure the client_secret attribute
+	ClientSecretEnv string = "TURBO_CLIENT_SECRET"
+	// Environment variable to configure the client_token_url attribute
+	ClientTokenURLEnv string = "TURBO_CLIENT_TOKEN_URL"
+	// Environment variable to configure the client_token attribute
+	ClientTokenEnv string = "TURBO_CLIENT_TOKEN"
+	// Environment variable to configure the client_ca_bundle attribute
+	ClientCABundleEnv string = "TURBO_CLIENT_CA_BUNDLE"
+	// Environment variable to configure the client_cert attribute
+	ClientCertEnv string = "TURBO_CLIENT_CERT"
+	// Environment variable to configure the client_key attribute
+	ClientKeyEnv string = "TURBO_CLIENT_KEY"
+	// Environment variable to configure the client_tls_min_version attribute
+	ClientTLSMinVersionEnv string = "TURBO_CLIENT_TLS_MIN_VERSION"
+	// Environment variable to configure the client_tls_server_name attribute
+	ClientTLSServerNameEnv string = "TURBO_CLIENT_TLS_SERVER_NAME"
+	// Environment variable to configure the skip_credentials_validation
+	// attribute
+	SkipCredentialsValidationEnv string = "TURBO_SKIP_CREDENTIALS_VALIDATION"
+	// Environment variable to configure the max_retries attribute
+	MaxRetriesEnv string = "TURBO_MAX_RETRIES"
+	// Environment variable to configure the retry_max_wait attribute
+	RetryMaxWaitEnv string = "TURBO_RETRY_MAX_WAIT"
+	// Environment variable to configure the max_requests_per_second attribute
+	MaxRequestsPerSecondEnv string = "TURBO_MAX_REQUESTS_PER_SECOND"
+	// Environment variable to configure the max_concurrent_requests attribute
+	MaxConcurrentRequestsEnv string = "TURBO_MAX_CONCURRENT_REQUESTS"
+	// Environment variable to configure the response_cache_ttl attribute
+	ResponseCacheTTLEnv string = "TURBO_RESPONSE_CACHE_TTL"
 	// Environment variable to configure the server_hostname attribute
 	ServerHostnameEnv string = "TURBO_SERVER_HOSTNAME"
+	// Environment variable to configure the server_url attribute
+	ServerURLEnv string = "TURBO_SERVER_URL"
+	// Environment variable to configure the server_port attribute
+	ServerPortEnv string = "TURBO_SERVER_PORT"
+	// Environment variable to configure the api_base_path attribute
+	APIBasePathEnv string = "TURBO_API_BASE_PATH"
+	// Environment variable to configure the http_proxy attribute
+	HTTPProxyEnv string = "TURBO_HTTP_PROXY"
+	// Environment variable to configure the request_timeout attribute
+	RequestTimeoutEnv string = "TURBO_REQUEST_TIMEOUT"
+	// Environment variable to configure the dial_timeout attribute
+	DialTimeoutEnv string = "TURBO_DIAL_TIMEOUT"
 )
 
 // Provider configuration default values
@@ -31,6 +90,22 @@ const (
 	DefaultProviderLogLevel string = "INFO"
 	// Default output log file if one is not provided
 	DefaultProviderLogFile string = "terraform-provider-turbonomic.log"
+	// Default format of the HTTP exchange log entries
+	DefaultProviderLogFormat string = api.LogFormatText
+	// Default number of bytes of each request and response body logged
+	DefaultProviderLogMaxBodySize int = 4096
+	// Default number of retries after a transient API failure
+	DefaultMaxRetries int = 3
+	// Default upper bound for the wait between retries
+	DefaultRetryMaxWait string = "30s"
+	// Default timeout of a single HTTP request.  Requests are only bounded
+	// by the resource timeouts by default.
+	DefaultRequestTimeout string = "0s"
+	// Default timeout to establish a connection to Turbonomic
+	DefaultDialTimeout string = "30s"
+	// Default lifetime of cached API responses.  The cache is disabled by
+	// default.
+	DefaultResponseCacheTTL string = "0s"
 )
 
 // Log file constants
@@ -46,12 +121,26 @@ type LoggingConfig struct {
 	LogLevel log.LogLevel
 	// The path to the log file
 	LogFile string
+	// Format of the HTTP exchange log entries, one of the api.LogFormatXxx
+	// constants
+	LogFormat string
+	// Number of bytes of each request and response body logged at the DEBUG
+	// and TRACE levels
+	LogMaxBodySize int
 }
 
+// Request metrics of every client configured by the provider process,
+// reported by LogRequestSummary, and the summary last logged for each
+var (
+	requestMetricsMutex sync.Mutex
+	requestMetrics      []*api.Metrics
+	loggedSummaries     = map[*api.Metrics]string{}
+)
+
 // Provider definition for object in Turbonomic.  The provider block defines the configuration for
 // REST client that communicates with the appliance
 func Provider() terraform.ResourceProvider {
-	return &schema.Provider{
+	provider := &schema.Provider{
 
 		Schema: map[string]*schema.Schema{
 
@@ -97,14 +186,60 @@ func Provider() terraform.ResourceProvider {
 					"environment variable `TURBO_PROVIDER_LOGFILE`. Defaults to " +
 					"`\"terraform-provider-turbonomic.log\"`.",
 			},
+			"provider_log_format": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ProviderLogFormatEnv,
+					DefaultProviderLogFormat,
+				),
+				ValidateFunc: validation.StringInSlice(api.LogFormats(), false),
+				Description: "Format of the entries logged for every request sent to " +
+					"Turbonomic. A value of `\"text\"` logs the request at the 'DEBUG' " +
+					"level along with the other provider messages. A value of `\"json\"` " +
+					"writes one JSON object per line to the provider log file with the " +
+					"request ID, method, endpoint, status and latency, for ingestion by " +
+					"log pipelines. Authorization headers, cookies, passwords and tokens " +
+					"are always redacted. This can also be set through the environment " +
+					"variable `TURBO_PROVIDER_LOG_FORMAT`. Defaults to `\"text\"`.",
+			},
+			"provider_log_max_body_size": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ProviderLogMaxBodySizeEnv,
+					DefaultProviderLogMaxBodySize,
+				),
+				ValidateFunc: validation.IntAtLeast(0),
+				Description: "Maximum number of bytes of each request and response " +
+					"body written to the log. Bodies are only logged at the 'DEBUG' and " +
+					"'TRACE' levels. A value of `0` omits the bodies. This can also be set " +
+					"through the environment variable `TURBO_PROVIDER_LOG_MAX_BODY_SIZE`. " +
+					"Defaults to `4096`.",
+			},
 
 			// -- API Server configuration --
 
+			"server_url": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ServerURLEnv,
+					"",
+				),
+				ValidateFunc: validateServerURL,
+				Description: "The URL of the Turbonomic REST API server, including the " +
+					"protocol and optionally the port, ie: `\"https://turbo.example.com:8443\"`. " +
+					"Takes the place of `server_hostname`, `server_protocol` and " +
+					"`server_port`; use `api_base_path` to set the path of the API. This " +
+					"can also be set through the environment variable `TURBO_SERVER_URL`.",
+			},
 			"server_hostname": &schema.Schema{
-				Type:        schema.TypeString,
-				Required:    true,
-				Description: "The hostname / IP address of the Turbonomic REST API server",
-				DefaultFunc: schema.EnvDefaultFunc(ServerHostnameEnv, nil),
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: "The hostname / IP address of the Turbonomic REST API server. " +
+					"Required unless `server_url` is set.",
+				DefaultFunc: schema.EnvDefaultFunc(ServerHostnameEnv, ""),
 			},
 			"server_protocol": &schema.Schema{
 				Type:     schema.TypeString,
@@ -113,6 +248,30 @@ func Provider() terraform.ResourceProvider {
 				Description: "The protocol the Turbonomic REST API server is using for " +
 					"communication. Defaults to https.",
 			},
+			"server_port": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ServerPortEnv,
+					0,
+				),
+				ValidateFunc: validation.IntBetween(0, 65535),
+				Description: "The port of the Turbonomic REST API server. A value of `0` " +
+					"uses the default port of `server_protocol`. This can also be set " +
+					"through the environment variable `TURBO_SERVER_PORT`. Defaults to `0`.",
+			},
+			"api_base_path": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					APIBasePathEnv,
+					api.APIURLPrefix,
+				),
+				Description: "The path under which the Turbonomic REST API is served, ie: " +
+					"`\"/turbo/api/v2\"` when Turbonomic is mounted under `/turbo` by a " +
+					"reverse proxy. This can also be set through the environment variable " +
+					"`TURBO_API_BASE_PATH`. Defaults to `\"/api/v2\"`.",
+			},
 
 			// -- REST client configuration --
 
@@ -122,21 +281,273 @@ func Provider() terraform.ResourceProvider {
 				Default:     false,
 				Description: "Whether or not to verify the server's certificate. Defaults to `false`.",
 			},
+			"http_proxy": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					HTTPProxyEnv,
+					"",
+				),
+				ValidateFunc: validateServerURL,
+				Description: "URL of the proxy every request to Turbonomic is sent through, " +
+					"ie: `\"http://proxy.example.com:3128\"`. When unset, the proxy is " +
+					"taken from the standard `HTTPS_PROXY`, `HTTP_PROXY` and `NO_PROXY` " +
+					"environment variables. This can also be set through the environment " +
+					"variable `TURBO_HTTP_PROXY`.",
+			},
+			"request_timeout": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					RequestTimeoutEnv,
+					DefaultRequestTimeout,
+				),
+				ValidateFunc: validateDuration,
+				Description: "Maximum duration of a single request to Turbonomic, as a " +
+					"duration such as `\"60s\"`. Each retry gets the full timeout. A " +
+					"value of `\"0s\"` bounds requests by the resource timeouts only. This " +
+					"can also be set through the environment variable " +
+					"`TURBO_REQUEST_TIMEOUT`. Defaults to `\"0s\"`.",
+			},
+			"dial_timeout": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					DialTimeoutEnv,
+					DefaultDialTimeout,
+				),
+				ValidateFunc: validateDuration,
+				Description: "Maximum duration to establish a connection to Turbonomic, as " +
+					"a duration such as `\"10s\"`. This can also be set through the " +
+					"environment variable `TURBO_DIAL_TIMEOUT`. Defaults to `\"30s\"`.",
+			},
+			"client_ca_bundle": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ClientCABundleEnv,
+					"",
+				),
+				Description: "CA certificates trusted to sign the server's certificate, in " +
+					"addition to the system roots. Either a path to a PEM file or the PEM " +
+					"content itself. This can also be set through the environment variable " +
+					"`TURBO_CLIENT_CA_BUNDLE`.",
+			},
+			"client_cert": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ClientCertEnv,
+					"",
+				),
+				Description: "Client certificate presented to the server for mutual TLS. " +
+					"Either a path to a PEM file or the PEM content itself. Requires " +
+					"`client_key`. This can also be set through the environment variable " +
+					"`TURBO_CLIENT_CERT`.",
+			},
+			"client_key": &schema.Schema{
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ClientKeyEnv,
+					"",
+				),
+				Description: "Private key of `client_cert`. Either a path to a PEM file or " +
+					"the PEM content itself. This can also be set through the environment " +
+					"variable `TURBO_CLIENT_KEY`.",
+			},
+			"client_tls_min_version": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ClientTLSMinVersionEnv,
+					"",
+				),
+				ValidateFunc: validation.StringInSlice(
+					append([]string{""}, api.TLSVersionNames()...),
+					false,
+				),
+				Description: "Minimum TLS version negotiated with the server, one of `1.0`, " +
+					"`1.1`, `1.2` or `1.3`. This can also be set through the environment " +
+					"variable `TURBO_CLIENT_TLS_MIN_VERSION`. Defaults to the Golang " +
+					"default.",
+			},
+			"client_tls_server_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ClientTLSServerNameEnv,
+					"",
+				),
+				Description: "Name used to verify the server's certificate and sent as SNI, " +
+					"when it differs from `server_hostname` (ie: when connecting through " +
+					"an IP address or a load balancer). This can also be set through the " +
+					"environment variable `TURBO_CLIENT_TLS_SERVER_NAME`.",
+			},
+			"max_retries": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					MaxRetriesEnv,
+					DefaultMaxRetries,
+				),
+				ValidateFunc: validation.IntAtLeast(0),
+				Description: "Maximum number of times a request to Turbonomic is retried " +
+					"after a connection error or a 429, 502, 503 or 504 response. Retries " +
+					"use exponential backoff with jitter and honor the `Retry-After` " +
+					"header. Only idempotent requests (GET, PUT, DELETE) are retried. A " +
+					"value of `0` disables retries. This can also be set through the " +
+					"environment variable `TURBO_MAX_RETRIES`. Defaults to `3`.",
+			},
+			"retry_max_wait": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					RetryMaxWaitEnv,
+					DefaultRetryMaxWait,
+				),
+				ValidateFunc: validateDuration,
+				Description: "Upper bound for the wait between retries, as a duration " +
+					"such as `\"30s\"` or `\"2m\"`. Waits requested by the server through " +
+					"`Retry-After` are also capped at this value. This can also be set " +
+					"through the environment variable `TURBO_RETRY_MAX_WAIT`. Defaults to " +
+					"`\"30s\"`.",
+			},
+			"max_requests_per_second": &schema.Schema{
+				Type:     schema.TypeFloat,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					MaxRequestsPerSecondEnv,
+					0.0,
+				),
+				ValidateFunc: validateNonNegativeFloat,
+				Description: "Average number of requests per second the provider sends to " +
+					"Turbonomic, shared by every resource and data source in the run. " +
+					"Requests above the rate wait for their turn. A value of `0` disables " +
+					"rate limiting. This can also be set through the environment variable " +
+					"`TURBO_MAX_REQUESTS_PER_SECOND`. Defaults to `0`.",
+			},
+			"max_concurrent_requests": &schema.Schema{
+				Type:     schema.TypeInt,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					MaxConcurrentRequestsEnv,
+					0,
+				),
+				ValidateFunc: validation.IntAtLeast(0),
+				Description: "Maximum number of requests the provider has in flight to " +
+					"Turbonomic at once, shared by every resource and data source in the " +
+					"run. A value of `0` means unlimited. This can also be set through the " +
+					"environment variable `TURBO_MAX_CONCURRENT_REQUESTS`. Defaults to `0`.",
+			},
+			"response_cache_ttl": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					ResponseCacheTTLEnv,
+					DefaultResponseCacheTTL,
+				),
+				ValidateFunc: validateDuration,
+				Description: "How long successful API reads are cached in memory for the " +
+					"duration of the run, as a duration such as `\"30s\"` or `\"5m\"`. " +
+					"Concurrent identical reads (ie: several data sources listing templates) " +
+					"are coalesced into a single request, and creating or deleting an " +
+					"object invalidates the cached reads of its collection. A value of " +
+					"`\"0s\"` disables the cache. This can also be set through the " +
+					"environment variable `TURBO_RESPONSE_CACHE_TTL`. Defaults to `\"0s\"`.",
+			},
 
 			// -- client credentials --
 
+			"skip_credentials_validation": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				DefaultFunc: schema.EnvDefaultFunc(
+					SkipCredentialsValidationEnv,
+					false,
+				),
+				Description: "Whether or not to skip the check made when the provider is " +
+					"configured, which connects to Turbonomic with the client credentials " +
+					"and reports whether DNS, TLS, authentication or the API path failed. " +
+					"Useful for offline plans. When skipped, the Turbonomic version is not " +
+					"detected and version specific features are validated by the server. " +
+					"This can also be set through the environment variable " +
+					"`TURBO_SKIP_CREDENTIALS_VALIDATION`. Defaults to `false`.",
+			},
+			"client_auth_method": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				// no default: an empty value would fail the validation below
+				DefaultFunc: schema.EnvDefaultFunc(
+					ClientAuthMethodEnv,
+					nil,
+				),
+				ValidateFunc: validation.StringInSlice([]string{
+					api.AuthMethodSession,
+					api.AuthMethodBasic,
+					api.AuthMethodOAuth2,
+					api.AuthMethodToken,
+				}, false),
+				Description: "How the client authenticates against Turbonomic. A value of " +
+					"`\"session\"` logs in once with `client_username` and `client_password` " +
+					"and reuses the session cookie, logging in again when the session " +
+					"expires. A value of `\"basic\"` sends `client_username` and " +
+					"`client_password` as HTTP basic auth on every request. A value of " +
+					"`\"oauth2\"` obtains access tokens with `client_id` and " +
+					"`client_secret` and refreshes them as they expire. A value of " +
+					"`\"token\"` sends `client_token` on every request. When not set, the " +
+					"method is inferred from the credentials provided: `client_token` " +
+					"selects `\"token\"`, `client_id` selects `\"oauth2\"`, otherwise " +
+					"`\"session\"` is used. This can also be set through the environment " +
+					"variable `TURBO_CLIENT_AUTH_METHOD`.",
+			},
 			"client_username": &schema.Schema{
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
 				Description: "Username for authenticating against Turbonomic",
 				DefaultFunc: schema.EnvDefaultFunc(ClientUsernameEnv, nil),
 			},
 			"client_password": &schema.Schema{
 				Type:        schema.TypeString,
-				Required:    true,
+				Optional:    true,
+				Sensitive:   true,
 				Description: "Password for authenticating against Turbonomic",
 				DefaultFunc: schema.EnvDefaultFunc(ClientPasswordEnv, nil),
 			},
+			"client_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: "OAuth2 client ID for authenticating against Turbonomic. This " +
+					"can also be set through the environment variable `TURBO_CLIENT_ID`.",
+				DefaultFunc: schema.EnvDefaultFunc(ClientIDEnv, nil),
+			},
+			"client_secret": &schema.Schema{
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+				Description: "OAuth2 client secret for authenticating against Turbonomic. " +
+					"This can also be set through the environment variable " +
+					"`TURBO_CLIENT_SECRET`.",
+				DefaultFunc: schema.EnvDefaultFunc(ClientSecretEnv, nil),
+			},
+			"client_token_url": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: "URL of the OAuth2 token endpoint. This can also be set " +
+					"through the environment variable `TURBO_CLIENT_TOKEN_URL`. Defaults " +
+					"to `/oauth2/token` on the Turbonomic server.",
+				DefaultFunc: schema.EnvDefaultFunc(ClientTokenURLEnv, nil),
+			},
+			"client_token": &schema.Schema{
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+				Description: "Static bearer token for authenticating against Turbonomic. " +
+					"This can also be set through the environment variable " +
+					"`TURBO_CLIENT_TOKEN`.",
+				DefaultFunc: schema.EnvDefaultFunc(ClientTokenEnv, nil),
+			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
@@ -146,18 +557,34 @@ func Provider() terraform.ResourceProvider {
 
 		DataSourcesMap: map[string]*schema.Resource{
 			"turbonomic_deployment_profile": dataSourceTurboDeploymentProfile(),
+			"turbonomic_entity":             dataSourceTurboEntity(),
 			"turbonomic_template":           dataSourceTurboTemplate(),
 			"turbonomic_market":             dataSourceTurboMarket(),
 			"turbonomic_market_policy":      dataSourceTurboMarketPolicy(),
+			"turbonomic_stats":              dataSourceTurboStats(),
 		},
-		ConfigureFunc: providerConfigure,
 	}
+	// Terraform usually kills the provider process once it is done with it,
+	// so the request summary is logged after every operation rather than
+	// when the process exits.
+	for _, resource := range provider.ResourcesMap {
+		logRequestSummaryAfterOperations(resource)
+	}
+	for _, dataSource := range provider.DataSourcesMap {
+		logRequestSummaryAfterOperations(dataSource)
+	}
+	// The REST client is bound to the provider's stop context so that
+	// in-flight requests are aborted when Terraform is interrupted.
+	provider.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
+		return providerConfigure(d, provider.StopContext())
+	}
+	return provider
 }
 
 // Uses the configuration values from the terraform file to configure
 // the provider.  Returns an authenticated REST client for communication
 // with Turbonomic.
-func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+func providerConfigure(d *schema.ResourceData, stopCtx context.Context) (interface{}, error) {
 	var ok bool
 
 	// parsing log level
@@ -194,52 +621,267 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 
 	// Construct the logging configuration and initialize the logging
 	logConfig := LoggingConfig{
-		LogLevel: logLevel,
-		LogFile:  logFile,
+		LogLevel:       logLevel,
+		LogFile:        logFile,
+		LogFormat:      d.Get("provider_log_format").(string),
+		LogMaxBodySize: d.Get("provider_log_max_body_size").(int),
 	}
 	log.Printf(
 		"[DEBUG] LoggingConfig: [%+v]",
 		logConfig,
 	)
-	InitLogger(logConfig)
+	logOutput := InitLogger(logConfig)
 	log.Printf(
 		"[INFO ] Provider log properly initialized. The log level is "+
 			"set to [%s].",
 		logConfig.LogLevel.String(),
 	)
 
+	server, serverErr := serverURL(d)
+	if serverErr != nil {
+		return nil, serverErr
+	}
+
 	config := Config{
 		// -- server configuration --
-		Server: url.URL{
-			Scheme: d.Get("server_protocol").(string),
-			Host:   d.Get("server_hostname").(string),
-		},
+		Server:      server,
+		APIBasePath: d.Get("api_base_path").(string),
 		// -- client configuration --
+		StopContext:       stopCtx,
 		ClientTLSInsecure: d.Get("client_tls_insecure").(bool),
 		ClientCredentials: api.ClientCredentials{
-			Username: d.Get("client_username").(string),
-			Password: d.Get("client_password").(string),
+			AuthMethod:   d.Get("client_auth_method").(string),
+			Username:     d.Get("client_username").(string),
+			Password:     d.Get("client_password").(string),
+			ClientID:     d.Get("client_id").(string),
+			ClientSecret: d.Get("client_secret").(string),
+			TokenURL:     d.Get("client_token_url").(string),
+			Token:        d.Get("client_token").(string),
 		},
 	}
+	if credErr := config.ClientCredentials.Validate(); credErr != nil {
+		return nil, credErr
+	}
+
+	// -- request metrics --
+	config.Metrics = api.NewMetrics(config.APIBasePath)
+	requestMetricsMutex.Lock()
+	requestMetrics = append(requestMetrics, config.Metrics)
+	requestMetricsMutex.Unlock()
+
+	// -- request logging configuration --
+	config.LogFormat = logConfig.LogFormat
+	config.LogOutput = logOutput
+	if logLevel == log.LevelDebug || logLevel == log.LevelTrace {
+		config.LogMaxBodySize = logConfig.LogMaxBodySize
+	}
+	config.SkipCredentialsValidation = d.Get("skip_credentials_validation").(bool)
+
+	// -- TLS configuration --
+	config.ClientTLS = api.TLSOptions{
+		CABundle:   d.Get("client_ca_bundle").(string),
+		ClientCert: d.Get("client_cert").(string),
+		ClientKey:  d.Get("client_key").(string),
+		MinVersion: d.Get("client_tls_min_version").(string),
+		ServerName: d.Get("client_tls_server_name").(string),
+	}
+	if tlsErr := config.ClientTLS.Validate(); tlsErr != nil {
+		return nil, tlsErr
+	}
+
+	// -- transport configuration --
+	// NOTE(ALL): the values were validated by validateServerURL and
+	//   validateDuration
+	if proxy := d.Get("http_proxy").(string); proxy != "" {
+		config.HTTPProxy, _ = url.Parse(proxy)
+	}
+	config.RequestTimeout, _ = time.ParseDuration(d.Get("request_timeout").(string))
+	config.DialTimeout, _ = time.ParseDuration(d.Get("dial_timeout").(string))
+
+	// -- retry configuration --
+	config.MaxRetries = d.Get("max_retries").(int)
+	// NOTE(ALL): the value was validated by validateDuration
+	config.RetryMaxWait, _ = time.ParseDuration(d.Get("retry_max_wait").(string))
+
+	// -- throttling configuration --
+	config.MaxRequestsPerSecond = d.Get("max_requests_per_second").(float64)
+	config.MaxConcurrentRequests = d.Get("max_concurrent_requests").(int)
+
+	// -- cache configuration --
+	// NOTE(ALL): the value was validated by validateDuration
+	config.ResponseCacheTTL, _ = time.ParseDuration(d.Get("response_cache_ttl").(string))
 
 	return config.Client()
 }
 
+// serverURL builds the URL of the Turbonomic server from either the
+// server_url attribute or the server_hostname, server_protocol and
+// server_port attributes.
+func serverURL(d *schema.ResourceData) (url.URL, error) {
+	hostname := d.Get("server_hostname").(string)
+	port := d.Get("server_port").(int)
+
+	if rawURL := d.Get("server_url").(string); rawURL != "" {
+		if hostname != "" {
+			return url.URL{}, fmt.Errorf(
+				"Only one of server_url and server_hostname may be set",
+			)
+		}
+		// NOTE(ALL): the value was validated by validateServerURL
+		server, _ := url.Parse(rawURL)
+		if port != 0 {
+			server.Host = net.JoinHostPort(server.Hostname(), strconv.Itoa(port))
+		}
+		return url.URL{Scheme: server.Scheme, Host: server.Host}, nil
+	}
+
+	if hostname == "" {
+		return url.URL{}, fmt.Errorf(
+			"One of server_url or server_hostname must be set",
+		)
+	}
+	if port != 0 {
+		hostname = net.JoinHostPort(hostname, strconv.Itoa(port))
+	}
+	return url.URL{
+		Scheme: d.Get("server_protocol").(string),
+		Host:   hostname,
+	}, nil
+}
+
+// validateServerURL is a schema.SchemaValidateFunc ensuring a string
+// attribute is empty or an absolute URL with a scheme and host and no path
+// (ie: "https://turbo.example.com:8443").
+func validateServerURL(v interface{}, k string) (ws []string, errors []error) {
+	value, ok := v.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %s to be string", k))
+		return
+	}
+	if value == "" {
+		return
+	}
+	parsed, parseErr := url.Parse(value)
+	if parseErr != nil {
+		errors = append(errors, fmt.Errorf("%s is not a valid URL: %s", k, parseErr))
+		return
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		errors = append(errors, fmt.Errorf(
+			"%s must include a scheme and host, ie: \"https://turbo.example.com\", got [%s]",
+			k,
+			value,
+		))
+	}
+	if strings.Trim(parsed.Path, "/") != "" {
+		errors = append(errors, fmt.Errorf(
+			"%s must not include a path, got [%s] (see api_base_path)",
+			k,
+			value,
+		))
+	}
+	return
+}
+
+// validateDuration is a schema.SchemaValidateFunc ensuring a string attribute
+// is a valid, non-negative Golang duration (ie: "30s", "5m").
+func validateDuration(v interface{}, k string) (ws []string, errors []error) {
+	value, ok := v.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %s to be string", k))
+		return
+	}
+	duration, parseErr := time.ParseDuration(value)
+	if parseErr != nil {
+		errors = append(errors, fmt.Errorf("%s is not a valid duration: %s", k, parseErr))
+		return
+	}
+	if duration < 0 {
+		errors = append(errors, fmt.Errorf("%s must not be negative, got [%s]", k, value))
+	}
+	return
+}
+
+// validateNonNegativeFloat is a schema.SchemaValidateFunc ensuring a float
+// attribute is not negative.
+func validateNonNegativeFloat(v interface{}, k string) (ws []string, errors []error) {
+	value, ok := v.(float64)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %s to be float", k))
+		return
+	}
+	if value < 0 {
+		errors = append(errors, fmt.Errorf("%s must not be negative, got [%f]", k, value))
+	}
+	return
+}
+
+// operationContext returns the context for a resource or data source
+// operation.  The context's deadline is the operation's timeout (see
+// schema.ResourceTimeout), which defaults to 20 minutes when the resource
+// does not declare one.  The caller must call the returned cancel function
+// once the operation completes.
+func operationContext(d *schema.ResourceData, timeoutKey string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), d.Timeout(timeoutKey))
+}
+
+// LogRequestSummary writes the number of requests, errors and latency of
+// every Turbonomic endpoint called by the provider process to the provider
+// log, unless it is unchanged since it was last written.  It shows which
+// endpoints made a run slow.
+func LogRequestSummary() {
+	requestMetricsMutex.Lock()
+	defer requestMetricsMutex.Unlock()
+
+	for _, metrics := range requestMetrics {
+		summary := metrics.Summary()
+		if summary == "" || summary == loggedSummaries[metrics] {
+			continue
+		}
+		loggedSummaries[metrics] = summary
+		log.Infof("Turbonomic API requests made during this run:\n%s", summary)
+	}
+}
+
+// logRequestSummaryAfterOperations wraps the CRUD functions of the resource
+// or data source so that LogRequestSummary is called after each of them.
+func logRequestSummaryAfterOperations(resource *schema.Resource) {
+	wrap := func(operation func(*schema.ResourceData, interface{}) error) func(*schema.ResourceData, interface{}) error {
+		return func(d *schema.ResourceData, meta interface{}) error {
+			defer LogRequestSummary()
+			return operation(d, meta)
+		}
+	}
+	if resource.Create != nil {
+		resource.Create = wrap(resource.Create)
+	}
+	if resource.Read != nil {
+		resource.Read = wrap(resource.Read)
+	}
+	if resource.Update != nil {
+		resource.Update = wrap(resource.Update)
+	}
+	if resource.Delete != nil {
+		resource.Delete = wrap(resource.Delete)
+	}
+}
+
 // Initialize the provider's shared logging instance. The shared log
 // will attempt to log to a file.  If an error is encountered while trying
 // to set up the log file , the error is captured with Golang stdlib "log"
-// and the default log writer is used.
-func InitLogger(logConfig LoggingConfig) {
+// and the default log writer is used.  Returns the writer the log output
+// goes to, which also receives the JSON request log entries.
+func InitLogger(logConfig LoggingConfig) io.Writer {
 	// Set the log level. If the log level is set to 'NONE', then return
 	// and do not continue with file logging
 	log.SetLevel(logConfig.LogLevel)
 	if logConfig.LogLevel == log.LevelNone {
-		return
+		return ioutil.Discard
 	}
 	// If the log file is set to stdlog, return. The log package uses
-	// stdlog by default
+	// stdlog by default, which writes to stderr
 	if logConfig.LogFile == LogFileStdLog {
-		return
+		return os.Stderr
 	}
 	// attempt to open the file for writing.  If the file doesn't already
 	// exist, feel free to create it for us.  If the file already exists,
@@ -257,7 +899,7 @@ func InitLogger(logConfig LoggingConfig) {
 		log.Printf(
 			"[INFO] Sending provider's log output to default io.Writer",
 		)
-		return
+		return os.Stderr
 	}
 	// No file errors - set the standard log to write to the file.
 	log.SetOutput(file)
@@ -265,4 +907,5 @@ func InitLogger(logConfig LoggingConfig) {
 		"[INFO ] Provider log set to write to [%s]",
 		logConfig.LogFile,
 	)
+	return file
 }
@@ -1,21 +1,30 @@
 package turbonomic
 
 import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api/cassette"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api/fake"
 )
 
-// var testAccProviders map[string]terraform.ResourceProvider
-// var testAccProvider *schema.Provider
+var testAccProviders map[string]terraform.ResourceProvider
+var testAccProvider *schema.Provider
 
-// func init() {
-// 	testAccProvider = Provider().(*schema.Provider)
-// 	testAccProviders = map[string]terraform.ResourceProvider{
-// 		"turbonomic": testAccProvider,
-// 	}
-// }
+func init() {
+	testAccProvider = Provider().(*schema.Provider)
+	testAccProviders = map[string]terraform.ResourceProvider{
+		"turbonomic": testAccProvider,
+	}
+}
 
 func TestProvider(t *testing.T) {
 	if err := Provider().(*schema.Provider).InternalValidate(); err != nil {
@@ -27,17 +36,56 @@ func TestProvider_impl(t *testing.T) {
 	var _ terraform.ResourceProvider = Provider()
 }
 
-// func testAccPreCheck(t *testing.T) {
-// 	if v := os.Getenv("SERVER_HOSTNAME"); v == "" {
-// 		t.Fatal("SERVER_HOSTNAME must be set for acceptance tests")
-// 	}
-// 	if v := os.Getenv("CLIENT_USERNAME"); v == "" {
-// 		t.Fatal("CLIENT_USERNAME must be set for acceptance tests")
-// 	}
-// 	if v := os.Getenv("CLIENT_PASSWORD"); v == "" {
-// 		t.Fatal("CLIENT_PASSWORD must be set for acceptance tests")
-// 	}
-// }
+// testAccFakeServer starts a fake Turbonomic server for an acceptance test.
+// The server is closed at the end of the test.
+func testAccFakeServer(t *testing.T) *fake.Server {
+	server := fake.NewServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testAccProviderConfig returns the provider block configuring the provider
+// against the fake server.  Acceptance tests prepend it to their
+// configuration.
+func testAccProviderConfig(server *fake.Server) string {
+	return fmt.Sprintf(`
+provider "turbonomic" {
+  server_url       = "%s"
+  client_username  = "%s"
+  client_password  = "%s"
+  provider_logfile = "-"
+}
+`, server.URL, fake.Username, fake.Password)
+}
+
+func TestProviderLogsRequestSummaryAfterOperations(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.AddMarket(api.TurboMarket{DisplayName: "Market"})
+	requestMetricsMutex.Lock()
+	configured := len(requestMetrics)
+	requestMetricsMutex.Unlock()
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "turbonomic_market" "test" {}
+`,
+				Check: func(*terraform.State) error {
+					requestMetricsMutex.Lock()
+					defer requestMetricsMutex.Unlock()
+					for _, metrics := range requestMetrics[configured:] {
+						if strings.Contains(loggedSummaries[metrics], api.MarketsPrefix) {
+							return nil
+						}
+					}
+					return fmt.Errorf("expected the request summary to be logged after reading the market")
+				},
+			},
+		},
+	})
+}
 
 // func skipIfEnvNotSet(t *testing.T, envs ...string) {
 // 	for _, k := range envs {
@@ -46,3 +94,106 @@ func TestProvider_impl(t *testing.T) {
 // 		}
 // 	}
 // }
+
+func TestProviderServerURL(t *testing.T) {
+	cases := []struct {
+		raw      map[string]interface{}
+		expected string
+		err      bool
+	}{
+		{
+			raw:      map[string]interface{}{"server_hostname": "turbo.example.com"},
+			expected: "https://turbo.example.com",
+		},
+		{
+			raw: map[string]interface{}{
+				"server_hostname": "turbo.example.com",
+				"server_protocol": "http",
+				"server_port":     8080,
+			},
+			expected: "http://turbo.example.com:8080",
+		},
+		{
+			raw:      map[string]interface{}{"server_url": "https://turbo.example.com:8443"},
+			expected: "https://turbo.example.com:8443",
+		},
+		{
+			raw: map[string]interface{}{
+				"server_url":  "https://turbo.example.com:8443",
+				"server_port": 9443,
+			},
+			expected: "https://turbo.example.com:9443",
+		},
+		{
+			raw: map[string]interface{}{
+				"server_url":      "https://turbo.example.com",
+				"server_hostname": "turbo.example.com",
+			},
+			err: true,
+		},
+		{
+			raw: map[string]interface{}{},
+			err: true,
+		},
+	}
+
+	providerSchema := Provider().(*schema.Provider).Schema
+	for _, c := range cases {
+		d := schema.TestResourceDataRaw(t, providerSchema, c.raw)
+		server, err := serverURL(d)
+		if c.err {
+			if err == nil {
+				t.Errorf("%v: expected an error, got [%s]", c.raw, server.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", c.raw, err)
+			continue
+		}
+		if server.String() != c.expected {
+			t.Errorf("%v: expected [%s], got [%s]", c.raw, c.expected, server.String())
+		}
+	}
+}
+
+// testCassetteClient returns a client replaying the named cassette from
+// testdata/cassettes.  With TURBO_CASSETTE_MODE=record, the client instead
+// talks to the server given by TURBO_SERVER_URL with the credentials from
+// TURBO_CLIENT_USERNAME and TURBO_CLIENT_PASSWORD, and (re)records the
+// cassette at the end of the test.
+func testCassetteClient(t *testing.T, name string) *api.Client {
+	path := filepath.Join("testdata", "cassettes", name+".json")
+	mode := cassette.ModeFromEnv()
+
+	server := url.URL{Scheme: "https", Host: "turbonomic.invalid"}
+	credentials := api.ClientCredentials{
+		Username: cassette.Redacted,
+		Password: cassette.Redacted,
+	}
+	if mode == cassette.ModeRecord {
+		serverURL, parseErr := url.Parse(os.Getenv(ServerURLEnv))
+		if parseErr != nil || serverURL.Host == "" {
+			t.Fatalf("%s must be set to record cassettes", ServerURLEnv)
+		}
+		server = url.URL{Scheme: serverURL.Scheme, Host: serverURL.Host}
+		credentials.Username = os.Getenv(ClientUsernameEnv)
+		credentials.Password = os.Getenv(ClientPasswordEnv)
+	}
+
+	recorder, recorderErr := cassette.New(path, mode, nil)
+	if recorderErr != nil {
+		t.Fatalf("cassette: %s", recorderErr)
+	}
+	t.Cleanup(func() {
+		if stopErr := recorder.Stop(); stopErr != nil {
+			t.Errorf("cassette: %s", stopErr)
+		}
+	})
+
+	// MaxRetries is left at zero: retries would only replay the same recorded
+	// failure
+	return api.NewClient(server, false, credentials, api.ClientOptions{
+		Transport: recorder,
+	})
+}
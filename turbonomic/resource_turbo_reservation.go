@@ -1,6 +1,7 @@
 package turbonomic
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -16,6 +17,22 @@ import (
 
 const (
 	reservationOperationTimeout = 5 * time.Minute
+	reservationPollInterval     = "3s"
+	reservationPollDelay        = "5s"
+)
+
+var (
+	// reservationPendingStatuses - statuses of reservations that are not
+	// placed yet, which wait_for_status may accept
+	reservationPendingStatuses = []string{"IN_PROGRESS", "LOADING", "RETRYING", "FUTURE"}
+	// reservationWaitStatuses - statuses wait_for_status accepts: the
+	// pending ones and UNFULFILLED, which Create fails on otherwise
+	reservationWaitStatuses = append(append([]string{}, reservationPendingStatuses...), "UNFULFILLED")
+	// reservationPlacedStatuses - statuses Create waits for, besides
+	// wait_for_status
+	reservationPlacedStatuses = []string{"PLACEMENT_SUCCEEDED", "RESERVED"}
+	// reservationPriorities - priorities of reserved workloads
+	reservationPriorities = []string{"LOW", "NORMAL", "HIGH"}
 )
 
 func resourceTurboReservation() *schema.Resource {
@@ -23,16 +40,27 @@ func resourceTurboReservation() *schema.Resource {
 
 		Create: resourceTurboReservationCreate,
 		Read:   resourceTurboReservationRead,
+		Update: resourceTurboReservationUpdate,
 		Delete: resourceTurboReservationDelete,
 
+		CustomizeDiff: resourceTurboReservationCustomizeDiff,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(reservationOperationTimeout),
+			Read:   schema.DefaultTimeout(reservationOperationTimeout),
+			Update: schema.DefaultTimeout(reservationOperationTimeout),
+			Delete: schema.DefaultTimeout(reservationOperationTimeout),
+		},
+
 		Schema: map[string]*schema.Schema{
 			autodoc.MetaAttribute: &schema.Schema{
 				Type:     schema.TypeBool,
 				Computed: true,
 				Description: fmt.Sprintf(
 					"%s Turbonomic Placement Recommendations.\n"+
-						"NOTE: Once placement recommendation is retrieved, it only exists in state file. \n"+
-						"\t Placement recommendatin is deleted by API itself when the call is completed "+
+						"NOTE: The reservation is refreshed from Turbonomic, and removed "+
+						"from state once it expires or is deleted so that the next "+
+						"apply reserves the capacity again. "+
 						"%s",
 					autodoc.MetaSummary,
 					autodoc.MetaImmutable,
@@ -55,34 +83,69 @@ func resourceTurboReservation() *schema.Resource {
 			},
 
 			"entity_name": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"entity_names", "demand"},
 				Description: fmt.Sprintf(
 					"Name of the instance to use for generating placement recommendation. "+
+						"Either `entity_name`, `entity_names` or `demand` is required. "+
 						"%s \"tftest.dev.foo.foo.com\"",
 					autodoc.MetaExample,
 				),
 			},
 
+			// OPTIONAL
 			"template_id": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"demand"},
 				Description: fmt.Sprintf(
-					"Template ID used for generating recommendation. "+
+					"Template ID used for generating recommendation.  Required "+
+						"unless `demand` is set. "+
 						"%s \"${data.turbonomic_template.template.id}\"",
 					autodoc.MetaExample,
 				),
 			},
 
-			// OPTIONAL
+			"entity_names": &schema.Schema{
+				Type:          schema.TypeList,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"entity_name", "demand"},
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				Description: fmt.Sprintf(
+					"Names of the instances to reserve capacity for, in the order "+
+						"of the `placement` list.  The first name is used as the "+
+						"name of the reservation. "+
+						"%s [\"web01.dev.foo.foo.com\", \"web02.dev.foo.foo.com\"]",
+					autodoc.MetaExample,
+				),
+			},
+
+			"instance_count": &schema.Schema{
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"demand"},
+				ValidateFunc:  validation.IntAtLeast(1),
+				Description: fmt.Sprintf(
+					"Number of instances to reserve capacity for.  Turbonomic "+
+						"names the instances beyond `entity_names`.  Defaults to "+
+						"the number of entity names. "+
+						"%s 20",
+					autodoc.MetaExample,
+				),
+			},
+
 			"constraint_ids": &schema.Schema{
-				Type:     schema.TypeSet,
-				Optional: true,
-				ForceNew: true,
-				Elem:     &schema.Schema{Type: schema.TypeString},
-				Set:      schema.HashString,
+				Type:          schema.TypeSet,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"demand"},
+				Elem:          &schema.Schema{Type: schema.TypeString},
+				Set:           schema.HashString,
 				Description: fmt.Sprintf(
 					"List of constraint policies to use for reservation "+
 						"%s [\"${data.turbonomic_market_policy.policy.id}\"]",
@@ -91,9 +154,10 @@ func resourceTurboReservation() *schema.Resource {
 			},
 
 			"deployment_profile_id": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"demand"},
 				Description: fmt.Sprintf(
 					"ID of the deployment profile associated with the template. "+
 						"%s \"${data.turbonomic_template.template.deployment_profile_id}\"",
@@ -101,6 +165,62 @@ func resourceTurboReservation() *schema.Resource {
 				),
 			},
 
+			"high_availability": &schema.Schema{
+				Type:          schema.TypeBool,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"demand"},
+				Description: "Whether the instances are placed on distinct " +
+					"hosts.  The number of hosts used is reported by " +
+					"`demand_result`.",
+			},
+
+			"priority": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"demand"},
+				ValidateFunc:  validation.StringInSlice(reservationPriorities, false),
+				Description: fmt.Sprintf(
+					"Priority of the reservation, one of `LOW`, `NORMAL` or "+
+						"`HIGH`.  Only supported by Turbonomic classic (6.x) "+
+						"%s \"HIGH\"",
+					autodoc.MetaExample,
+				),
+			},
+
+			"geographic_redundancy": &schema.Schema{
+				Type:          schema.TypeBool,
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"demand"},
+				Description: "Whether the instances are placed in distinct " +
+					"datacenters.  The datacenter of each instance is reported " +
+					"by `placement`.  Only supported by Turbonomic classic (6.x)",
+			},
+
+			"demand": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem:     resourceTurboReservationDemand(),
+				ConflictsWith: []string{
+					"entity_name",
+					"entity_names",
+					"template_id",
+					"instance_count",
+					"constraint_ids",
+					"deployment_profile_id",
+					"high_availability",
+					"priority",
+					"geographic_redundancy",
+				},
+				Description: "Workloads reserved together, each from its own " +
+					"template.  Either all of them are placed or none is.  The " +
+					"first entity name of the first demand is used as the name " +
+					"of the reservation.",
+			},
+
 			"reservation_reserve_time": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
@@ -123,6 +243,18 @@ func resourceTurboReservation() *schema.Resource {
 				),
 			},
 
+			"reservation_deploy_time": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Description: fmt.Sprintf(
+					"timestamp at which the reserved workloads are deployed. Only "+
+						"supported by Turbonomic classic (6.x) "+
+						"%s \"2019-01-01T00:00:00Z\"",
+					autodoc.MetaExample,
+				),
+			},
+
 			// bool value specifying whether we're creating a blocking/non-blocking request
 			// false = nonblocking, true = blocking
 			"reservation_blocking_req": &schema.Schema{
@@ -137,26 +269,347 @@ func resourceTurboReservation() *schema.Resource {
 				),
 			},
 
+			"required_status": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				ValidateFunc: validation.StringInSlice([]string{
+					"RESERVED",
+					"PLACEMENT_SUCCEEDED",
+				}, false),
+				Description: fmt.Sprintf(
+					"If set, the reservation is replaced when the status "+
+						"refreshed from Turbonomic differs, ie: when a market "+
+						"change drops it from `RESERVED` to `UNFULFILLED`. "+
+						"%s \"RESERVED\"",
+					autodoc.MetaExample,
+				),
+			},
+
+			"wait_for_status": &schema.Schema{
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice(reservationWaitStatuses, false),
+				},
+				Set: schema.HashString,
+				Description: fmt.Sprintf(
+					"Statuses, besides `PLACEMENT_SUCCEEDED` and `RESERVED`, that "+
+						"complete the creation of the reservation.  Add `FUTURE` to "+
+						"accept reservations that start in the future without "+
+						"waiting for them to be placed.  Accepts `IN_PROGRESS`, "+
+						"`LOADING`, `RETRYING`, `FUTURE` and `UNFULFILLED`.  "+
+						"Creation fails on reservations Turbonomic could not "+
+						"place yet, ie: `UNFULFILLED` ones, unless `UNFULFILLED` "+
+						"is accepted. "+
+						"%s [\"FUTURE\"]",
+					autodoc.MetaExample,
+				),
+			},
+
+			"poll_interval": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      reservationPollInterval,
+				ValidateFunc: validateDuration,
+				Description: fmt.Sprintf(
+					"Interval between two reads of the reservation status while "+
+						"waiting for `wait_for_status`, as a duration such as "+
+						"`\"10s\"`.  A value of `\"0s\"` polls with an exponential "+
+						"backoff.  DEFAULT: `\"%s\"`",
+					reservationPollInterval,
+				),
+			},
+
+			"poll_delay": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      reservationPollDelay,
+				ValidateFunc: validateDuration,
+				Description: fmt.Sprintf(
+					"Delay before the first read of the reservation status, as a "+
+						"duration such as `\"30s\"`.  DEFAULT: `\"%s\"`",
+					reservationPollDelay,
+				),
+			},
+
 			//COMPUTED
 			"compute_provider": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: "Generated recommendation for compute placememt " +
+					"of the first instance",
+			},
+
+			"storage_provider": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: "Generated recommendation for storage placememt " +
+					"of the first instance",
+			},
+
+			"placement": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     resourceTurboReservationPlacement(),
+				Description: "Generated recommendations for every instance, " +
+					"ie: `placement[count.index]`.  Empty until the instances " +
+					"are placed.",
+			},
+
+			"demand_result": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     resourceTurboReservationDemandResult(),
+				Description: "Generated recommendations per `demand` block, in " +
+					"the same order.  Reservations without `demand` blocks have " +
+					"a single result.",
+			},
+
+			"status": &schema.Schema{
 				Type:        schema.TypeString,
 				Computed:    true,
-				Description: "Generated recommendation for compute placememt",
+				Description: "Status of the placement recommendation",
+			},
+		},
+	}
+}
+
+// resourceTurboReservationDemand defines the schema of a demand block.  This
+// loosely translates to a `ReservationParameter`.
+func resourceTurboReservationDemand() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"template_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+				Description: fmt.Sprintf(
+					"Template ID of the workloads. "+
+						"%s \"${data.turbonomic_template.db.id}\"",
+					autodoc.MetaExample,
+				),
+			},
+			"entity_names": &schema.Schema{
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				MinItems: 1,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Description: fmt.Sprintf(
+					"Names of the workloads, in the order of the `placement` "+
+						"list of the demand's result. "+
+						"%s [\"db01.dev.foo.foo.com\"]",
+					autodoc.MetaExample,
+				),
+			},
+			"instance_count": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description: fmt.Sprintf(
+					"Number of workloads.  Defaults to the number of entity "+
+						"names. "+
+						"%s 2",
+					autodoc.MetaExample,
+				),
+			},
+			"constraint_ids": &schema.Schema{
+				Type:     schema.TypeSet,
+				Optional: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Set:      schema.HashString,
+				Description: fmt.Sprintf(
+					"Constraint policies of the workloads. "+
+						"%s [\"${data.turbonomic_market_policy.policy.id}\"]",
+					autodoc.MetaExample,
+				),
+			},
+			"deployment_profile_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				Description: fmt.Sprintf(
+					"ID of the deployment profile of the workloads. "+
+						"%s \"${data.turbonomic_template.db.deployment_profile_id}\"",
+					autodoc.MetaExample,
+				),
 			},
+			"high_availability": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Whether the workloads are placed on distinct hosts",
+			},
+			"priority": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(reservationPriorities, false),
+				Description: fmt.Sprintf(
+					"Priority of the workloads, one of `LOW`, `NORMAL` or `HIGH`.  "+
+						"Only supported by Turbonomic classic (6.x) "+
+						"%s \"HIGH\"",
+					autodoc.MetaExample,
+				),
+			},
+			"geographic_redundancy": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Description: "Whether the workloads are placed in distinct " +
+					"datacenters.  Only supported by Turbonomic classic (6.x)",
+			},
+		},
+	}
+}
 
+// resourceTurboReservationDemandResult defines the schema of the result of
+// a demand block: the placements of its workloads
+func resourceTurboReservationDemandResult() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"template_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Template ID of the demand",
+			},
+			"host_count": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+				Description: "Number of distinct hosts the workloads are " +
+					"placed on.  Equals the number of placements when " +
+					"`high_availability` is satisfied.",
+			},
+			"datacenter_count": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+				Description: "Number of distinct datacenters the workloads are " +
+					"placed in.  Only reported with `geographic_redundancy`.",
+			},
+			"placement": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     resourceTurboReservationPlacement(),
+				Description: "Generated recommendations for the workloads of " +
+					"the demand.  Empty until the workloads are placed.",
+			},
+		},
+	}
+}
+
+// resourceTurboReservationPlacement defines the schema of the placement of
+// an instance.  This loosely translates to a `DemandEntity` and its
+// `Placement`.
+func resourceTurboReservationPlacement() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"entity_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Name of the instance. "+
+						"%s \"web01.dev.foo.foo.com\"",
+					autodoc.MetaExample,
+				),
+			},
+			"entity_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "UUID of the instance's demand entity",
+			},
+			"compute_provider": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the first compute provider of the instance",
+			},
 			"storage_provider": &schema.Schema{
 				Type:        schema.TypeString,
 				Computed:    true,
-				Description: "Generated recommendation for storage placememt",
+				Description: "Name of the first storage provider of the instance",
+			},
+			"datacenter": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: "Name of the datacenter of the first compute " +
+					"provider.  Only reported with `geographic_redundancy`.",
+			},
+			"compute_resource": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        resourceTurboReservationProvider(false),
+				Description: "Compute providers of the instance, ie: hosts",
 			},
+			"storage_resource": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        resourceTurboReservationProvider(true),
+				Description: "Storage providers of the instance, ie: datastores",
+			},
+		},
+	}
+}
 
-			"status": &schema.Schema{
+// resourceTurboReservationProvider defines the schema of a provider of a
+// placed instance and the stats it provides.  This loosely translates to a
+// `ComputeResource` or a `StorageResource`.
+func resourceTurboReservationProvider(storage bool) *schema.Resource {
+	r := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"provider": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+				Description: fmt.Sprintf(
+					"Name of the provider. "+
+						"%s \"psc01n06.esx.foo.foo.com\"",
+					autodoc.MetaExample,
+				),
+			},
+			"provider_id": &schema.Schema{
 				Type:        schema.TypeString,
 				Computed:    true,
-				Description: "Status of the placement recommendation",
+				Description: "UUID of the provider",
+			},
+			"stat": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Resource statistic name",
+						},
+						"units": &schema.Schema{
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Resource statistic units",
+						},
+						"value": &schema.Schema{
+							Type:        schema.TypeFloat,
+							Computed:    true,
+							Description: "Resource statistic value",
+						},
+					},
+				},
+				Description: "Resources placed on the provider, ie: `diskSize`",
 			},
 		},
 	}
+	if storage {
+		r.Schema["disk_type"] = &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+			Description: fmt.Sprintf(
+				"Type of the storage resource. "+
+					"%s \"disk\"",
+				autodoc.MetaExample,
+			),
+		}
+	}
+	return r
 }
 
 func resourceTurboReservationCreate(d *schema.ResourceData, meta interface{}) error {
@@ -165,8 +618,6 @@ func resourceTurboReservationCreate(d *schema.ResourceData, meta interface{}) er
 	client := meta.(*api.Client)
 
 	resCreate := api.ReservationCreate{}
-	resDepParam := api.ReservationDeploymentParameter{}
-	resPlcParam := api.ReservationPlacementParameter{}
 
 	var err error
 	var attr interface{}
@@ -176,21 +627,11 @@ func resourceTurboReservationCreate(d *schema.ResourceData, meta interface{}) er
 		resCreate.Action = attr.(string)
 	}
 
-	if attr, ok = d.GetOk("deployment_profile_id"); ok {
-		resDepParam.DeploymentProfileID = attr.(string)
-	}
-
-	if attr, ok = d.GetOk("entity_name"); ok {
-		entityNames := make([]string, 1)
-		entityNames[0] = attr.(string)
-		resPlcParam.EntityNames = entityNames
-		resPlcParam.Count = 1
-		resCreate.DemandName = attr.(string)
-	}
-
-	if attr, ok = d.GetOk("template_id"); ok {
-		resPlcParam.TemplateID = attr.(string)
+	resCreate.Parameters, err = buildReservationParameters(d)
+	if err != nil {
+		return err
 	}
+	resCreate.DemandName = resCreate.Parameters[0].PlacementParameters.EntityNames[0]
 
 	if attr, ok = d.GetOk("reservation_reserve_time"); ok {
 		resCreate.ReserveDateTime = attr.(string)
@@ -200,17 +641,14 @@ func resourceTurboReservationCreate(d *schema.ResourceData, meta interface{}) er
 		resCreate.ExpireDateTime = attr.(string)
 	}
 
-	if attr, ok = d.GetOk("constraint_ids"); ok {
-		resPlcParam.ConstraintIDs = convertStringSet(attr.(*schema.Set))
+	if attr, ok = d.GetOk("reservation_deploy_time"); ok {
+		resCreate.DeployDateTime = attr.(string)
 	}
 
-	resParams := make([]api.ReservationParameter, 1)
-	resParams[0].PlacementParameters = resPlcParam
-	resParams[0].DeploymentParameters = resDepParam
+	ctx, cancel := operationContext(d, schema.TimeoutCreate)
+	defer cancel()
 
-	resCreate.Parameters = resParams
-
-	res, err := client.CreateReservation(&resCreate, d.Get("reservation_blocking_req").(bool))
+	res, err := client.CreateReservation(ctx, &resCreate, d.Get("reservation_blocking_req").(bool))
 	if err != nil {
 		return err
 	}
@@ -218,19 +656,48 @@ func resourceTurboReservationCreate(d *schema.ResourceData, meta interface{}) er
 	d.SetId(res.UUID)
 
 	//Wait for the Reservation resource to be ready
+	target := append([]string{}, reservationPlacedStatuses...)
+	if attr, ok = d.GetOk("wait_for_status"); ok {
+		target = append(target, convertStringSet(attr.(*schema.Set))...)
+	}
+	pending := []string{}
+	for _, status := range reservationPendingStatuses {
+		if !stringInSlice(status, target) {
+			pending = append(pending, status)
+		}
+	}
+	pollInterval, _ := time.ParseDuration(d.Get("poll_interval").(string))
+	pollDelay, _ := time.ParseDuration(d.Get("poll_delay").(string))
+	deadline, _ := ctx.Deadline()
+
 	stateConf := &resource.StateChangeConf{
-		Pending:    []string{"IN_PROGRESS", "LOADING", "RETRYING", "FUTURE", "UNFULFILLED"},
-		Target:     []string{"PLACEMENT_SUCCEEDED", "RESERVED"},
-		Refresh:    refreshReservation(d, meta),
-		Timeout:    2 * time.Minute,
-		MinTimeout: 3 * time.Second,
-		Delay:      5 * time.Second,
+		Pending:      pending,
+		Target:       target,
+		Refresh:      refreshReservation(ctx, d, meta),
+		Timeout:      time.Until(deadline),
+		PollInterval: pollInterval,
+		Delay:        pollDelay,
 	}
 
-	_, err = stateConf.WaitForState()
+	var result interface{}
+	result, err = stateConf.WaitForState()
+	if err == nil {
+		// reservations accepted before their placement have no placements
+		// yet
+		res = result.(*api.ReservationResponse)
+		var datacenters map[string]string
+		datacenters, err = readReservationDatacenters(ctx, client, d, res)
+		if err == nil {
+			err = setResourceDataFromReservation(d, res, datacenters)
+		}
+	}
 
 	if err != nil {
-		_ = client.DeleteReservation(res.UUID)
+		// Clean up with a fresh context: the create context may be the
+		// reason the wait failed.
+		deleteCtx, deleteCancel := operationContext(d, schema.TimeoutDelete)
+		defer deleteCancel()
+		_ = client.DeleteReservation(deleteCtx, res.UUID)
 		d.SetId("")
 		return fmt.Errorf("error waiting for turbonomic reservation id: %s Error: %s", res.UUID, err)
 	}
@@ -238,13 +705,87 @@ func resourceTurboReservationCreate(d *schema.ResourceData, meta interface{}) er
 	return nil
 }
 
-// resourceTurboReservationRead - placeholder read function(required by resource schema)
-// Reservation doesn't exist in Turbonomic.
-// It's only stored in state file. State file is populated at create time
+// resourceTurboReservationRead refreshes the status and placement of the
+// reservation.  Reservations that expired or were deleted outside of
+// Terraform are removed from state.
 func resourceTurboReservationRead(d *schema.ResourceData, meta interface{}) error {
 	log.Tracef("resource_turbo_reservation.go#Read")
 
-	return nil
+	client := meta.(*api.Client)
+
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	res, readErr := client.ReadReservation(ctx, d.Id())
+	if api.IsNotFound(readErr) {
+		log.Infof("Reservation [%s] no longer exists, removing it from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if reservationExpired(res, time.Now()) {
+		log.Infof("Reservation [%s] expired at [%s], removing it from state", d.Id(), res.ExpireDateTime)
+		d.SetId("")
+		return nil
+	}
+
+	log.Debugf("Read reservation: [%s] [%s]", res.UUID, res.Status)
+
+	datacenters, dcErr := readReservationDatacenters(ctx, client, d, res)
+	if dcErr != nil {
+		return dcErr
+	}
+	return setResourceDataFromReservation(d, res, datacenters)
+}
+
+// resourceTurboReservationUpdate - only required_status can be updated, and
+// it only affects the plan
+func resourceTurboReservationUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Tracef("resource_turbo_reservation.go#Update")
+
+	return resourceTurboReservationRead(d, meta)
+}
+
+// resourceTurboReservationCustomizeDiff checks that the Turbonomic server
+// supports the options of a new reservation, and forces the replacement of a
+// reservation whose refreshed status differs from required_status
+func resourceTurboReservationCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	log.Tracef("resource_turbo_reservation.go#CustomizeDiff")
+
+	if d.Id() == "" {
+		// arguments that are not known yet are validated by Create.  Every
+		// option is assumed to be supported if the version is not known.
+		resParams, paramErr := buildReservationParameters(d)
+		if paramErr != nil {
+			return nil
+		}
+		return meta.(*api.Client).CheckReservationCapabilities(&api.ReservationCreate{
+			DeployDateTime: d.Get("reservation_deploy_time").(string),
+			Parameters:     resParams,
+		})
+	}
+
+	// reservations that are not placed yet, ie: FUTURE reservations
+	// accepted by wait_for_status, are left to complete
+	requiredStatus := d.Get("required_status").(string)
+	status := d.Get("status").(string)
+	if requiredStatus == "" || status == requiredStatus ||
+		stringInSlice(status, reservationPendingStatuses) {
+		return nil
+	}
+
+	log.Infof(
+		"Reservation [%s] is [%s] rather than [%s], replacing it",
+		d.Id(),
+		status,
+		requiredStatus,
+	)
+	if err := d.SetNewComputed("status"); err != nil {
+		return err
+	}
+	return d.ForceNew("status")
 }
 
 // resourceTurboReservationDelete - reservation delete function(required by resource schema)
@@ -255,33 +796,300 @@ func resourceTurboReservationDelete(d *schema.ResourceData, meta interface{}) er
 	log.Tracef("resource_turbo_reservation.go#Delete")
 
 	client := meta.(*api.Client)
-	_, readErr := client.ReadReservation(d.Id())
 
-	if readErr == nil {
-		return client.DeleteReservation(d.Id())
+	ctx, cancel := operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	_, readErr := client.ReadReservation(ctx, d.Id())
+	if api.IsNotFound(readErr) {
+		// the reservation already expired or was deleted
+		return nil
+	}
+	if readErr != nil {
+		return readErr
+	}
+
+	deleteErr := client.DeleteReservation(ctx, d.Id())
+	if api.IsNotFound(deleteErr) {
+		return nil
 	}
+	return deleteErr
+}
 
-	return nil
+// resourceGetter reads the attributes of a ResourceData or a ResourceDiff
+type resourceGetter interface {
+	Get(key string) interface{}
 }
 
-func setResourceDataFromReservation(d *schema.ResourceData, r *api.ReservationResponse) error {
+// buildReservationParameters constructs the ReservationParameter of every
+// demand block, or of the top-level demand arguments, from the ResourceData
+// or ResourceDiff reference
+func buildReservationParameters(d resourceGetter) ([]api.ReservationParameter, error) {
+	log.Tracef("buildReservationParameters")
+
+	if demands := d.Get("demand").([]interface{}); len(demands) > 0 {
+		resParams := make([]api.ReservationParameter, len(demands))
+		for idx, demand := range demands {
+			resParam, paramErr := demandToReservationParameter(demand.(map[string]interface{}))
+			if paramErr != nil {
+				return nil, fmt.Errorf("demand.%d: %s", idx, paramErr)
+			}
+			resParams[idx] = *resParam
+		}
+		return resParams, nil
+	}
+
+	demand := map[string]interface{}{
+		"template_id":           d.Get("template_id"),
+		"entity_names":          d.Get("entity_names"),
+		"instance_count":        d.Get("instance_count"),
+		"constraint_ids":        d.Get("constraint_ids"),
+		"deployment_profile_id": d.Get("deployment_profile_id"),
+		"high_availability":     d.Get("high_availability"),
+		"priority":              d.Get("priority"),
+		"geographic_redundancy": d.Get("geographic_redundancy"),
+	}
+	if name := d.Get("entity_name").(string); name != "" {
+		demand["entity_names"] = []interface{}{name}
+	}
+	if len(demand["entity_names"].([]interface{})) == 0 {
+		return nil, fmt.Errorf("Either entity_name, entity_names or demand is required")
+	}
+	if demand["template_id"].(string) == "" {
+		return nil, fmt.Errorf("template_id is required unless demand is set")
+	}
+	resParam, paramErr := demandToReservationParameter(demand)
+	if paramErr != nil {
+		return nil, paramErr
+	}
+	return []api.ReservationParameter{*resParam}, nil
+}
+
+// demandToReservationParameter converts a demand map[string]interface{}
+// into a ReservationParameter.  The count defaults to the number of entity
+// names.
+func demandToReservationParameter(demand map[string]interface{}) (*api.ReservationParameter, error) {
+	resParam := api.ReservationParameter{}
+	resParam.DeploymentParameters.DeploymentProfileID = demand["deployment_profile_id"].(string)
+	resParam.DeploymentParameters.HighAvailability = demand["high_availability"].(bool)
+	resParam.DeploymentParameters.Priority = demand["priority"].(string)
+	resParam.PlacementParameters.GeographicRedundancy = demand["geographic_redundancy"].(bool)
+	resParam.PlacementParameters.TemplateID = demand["template_id"].(string)
+
+	for _, name := range demand["entity_names"].([]interface{}) {
+		resParam.PlacementParameters.EntityNames = append(resParam.PlacementParameters.EntityNames, name.(string))
+	}
+	if set, ok := demand["constraint_ids"].(*schema.Set); ok && set.Len() > 0 {
+		resParam.PlacementParameters.ConstraintIDs = convertStringSet(set)
+	}
+
+	resParam.PlacementParameters.Count = len(resParam.PlacementParameters.EntityNames)
+	if count := demand["instance_count"].(int); count != 0 {
+		if count < resParam.PlacementParameters.Count {
+			return nil, fmt.Errorf(
+				"instance_count [%d] is lower than the number of entity names [%d]",
+				count,
+				resParam.PlacementParameters.Count,
+			)
+		}
+		resParam.PlacementParameters.Count = count
+	}
+	return &resParam, nil
+}
+
+// readReservationDatacenters returns the names of the datacenters of the
+// hosts the reservation is placed on, keyed by host UUID.  The datacenters
+// are only read for reservations with geographic redundancy.
+func readReservationDatacenters(ctx context.Context, client *api.Client, d *schema.ResourceData, r *api.ReservationResponse) (map[string]string, error) {
+	log.Tracef("readReservationDatacenters")
+
+	datacenters := map[string]string{}
+	resParams, paramErr := buildReservationParameters(d)
+	if paramErr != nil {
+		return datacenters, nil
+	}
+	geographicRedundancy := false
+	for _, resParam := range resParams {
+		geographicRedundancy = geographicRedundancy || resParam.PlacementParameters.GeographicRedundancy
+	}
+	if !geographicRedundancy {
+		return datacenters, nil
+	}
+
+	for _, entity := range r.DemandEntities {
+		for _, res := range entity.Placements.ComputeResources {
+			if _, ok := datacenters[res.Provider.UUID]; ok || res.Provider.ClassName != api.ClassNamePhysicalMachine {
+				continue
+			}
+			host, readErr := client.ReadEntity(ctx, res.Provider.UUID)
+			if api.IsNotFound(readErr) {
+				// the host was removed since the placement
+				datacenters[res.Provider.UUID] = ""
+				continue
+			}
+			if readErr != nil {
+				return nil, readErr
+			}
+			dc, _ := host.ProviderOfClass(api.ClassNameDataCenter)
+			datacenters[res.Provider.UUID] = dc.DisplayName
+		}
+	}
+	return datacenters, nil
+}
+
+func setResourceDataFromReservation(d *schema.ResourceData, r *api.ReservationResponse, datacenters map[string]string) error {
 	log.Tracef("resource_turbo_reservation.go#setResourceDataFromReservation")
 
-	//check that demand ents is = count
-	if len(r.DemandEntities) < 1 {
+	// reservations that are not placed yet may not report their demand
+	// entities, ie: FUTURE reservations accepted by wait_for_status
+	if len(r.DemandEntities) < 1 && !stringInSlice(r.Status, reservationWaitStatuses) {
 		return fmt.Errorf(
 			"Reservation response is not in the expected format. \n"+
-				"DemandEnitites is not equal to 1. \n"+
+				"DemandEnitites is empty. \n"+
 				"Response: [%+v]",
 			r)
 	}
 
-	d.Set("compute_provider", r.DemandEntities[0].Placements.ComputeResources[0].Provider.DisplayName)
-	d.Set("storage_provider", r.DemandEntities[0].Placements.StorageResources[0].Provider.DisplayName)
+	// the demand entities are returned in the order of the parameters
+	resParams, paramErr := buildReservationParameters(d)
+	if paramErr != nil {
+		resParams = []api.ReservationParameter{{}}
+	}
+	demandResults := make([]interface{}, len(resParams))
+	demandPlacements := make([][]interface{}, len(resParams))
+	for idx, resParam := range resParams {
+		demandPlacements[idx] = []interface{}{}
+		demandResults[idx] = map[string]interface{}{
+			"template_id": resParam.PlacementParameters.TemplateID,
+		}
+	}
+
+	// reservations that are not placed (anymore) have no placements
+	placements := make([]interface{}, 0, len(r.DemandEntities))
+	demandIdx, demandCount := 0, 0
+	for _, entity := range r.DemandEntities {
+		for demandIdx < len(resParams)-1 && demandCount >= resParams[demandIdx].PlacementParameters.Count {
+			demandIdx++
+			demandCount = 0
+		}
+		demandCount++
+		if len(entity.Placements.ComputeResources) == 0 && len(entity.Placements.StorageResources) == 0 {
+			continue
+		}
+		placement := demandEntityToMapstruct(entity, datacenters)
+		placements = append(placements, placement)
+		demandPlacements[demandIdx] = append(demandPlacements[demandIdx], placement)
+	}
+	for idx, demandResult := range demandResults {
+		hosts := map[string]bool{}
+		dcs := map[string]bool{}
+		for _, placement := range demandPlacements[idx] {
+			if host := placement.(map[string]interface{})["compute_provider"].(string); host != "" {
+				hosts[host] = true
+			}
+			if dc := placement.(map[string]interface{})["datacenter"].(string); dc != "" {
+				dcs[dc] = true
+			}
+		}
+		demandResult.(map[string]interface{})["placement"] = demandPlacements[idx]
+		demandResult.(map[string]interface{})["host_count"] = len(hosts)
+		demandResult.(map[string]interface{})["datacenter_count"] = len(dcs)
+	}
+
+	computeProvider := ""
+	storageProvider := ""
+	if len(placements) > 0 {
+		computeProvider = placements[0].(map[string]interface{})["compute_provider"].(string)
+		storageProvider = placements[0].(map[string]interface{})["storage_provider"].(string)
+	}
+
+	d.Set("compute_provider", computeProvider)
+	d.Set("storage_provider", storageProvider)
+	d.Set("placement", placements)
+	d.Set("demand_result", demandResults)
 	d.Set("status", r.Status)
 	return nil
 }
 
+// demandEntityToMapstruct converts a placed DemandEntity into a
+// map[string]interface{} of the placement list.  The datacenter is looked
+// up by host UUID.
+func demandEntityToMapstruct(entity api.DemandEntity, datacenters map[string]string) map[string]interface{} {
+	computeResources := make([]interface{}, len(entity.Placements.ComputeResources))
+	for idx, res := range entity.Placements.ComputeResources {
+		computeResources[idx] = map[string]interface{}{
+			"provider":    res.Provider.DisplayName,
+			"provider_id": res.Provider.UUID,
+			"stat":        placementStatsToList(res.Stats),
+		}
+	}
+	storageResources := make([]interface{}, len(entity.Placements.StorageResources))
+	for idx, res := range entity.Placements.StorageResources {
+		storageResources[idx] = map[string]interface{}{
+			"provider":    res.Provider.DisplayName,
+			"provider_id": res.Provider.UUID,
+			"disk_type":   res.Type,
+			"stat":        placementStatsToList(res.Stats),
+		}
+	}
+
+	computeProvider := ""
+	datacenter := ""
+	if len(entity.Placements.ComputeResources) > 0 {
+		computeProvider = entity.Placements.ComputeResources[0].Provider.DisplayName
+		datacenter = datacenters[entity.Placements.ComputeResources[0].Provider.UUID]
+	}
+	storageProvider := ""
+	if len(entity.Placements.StorageResources) > 0 {
+		storageProvider = entity.Placements.StorageResources[0].Provider.DisplayName
+	}
+
+	return map[string]interface{}{
+		"entity_name":      entity.DisplayName,
+		"entity_id":        entity.UUID,
+		"compute_provider": computeProvider,
+		"storage_provider": storageProvider,
+		"datacenter":       datacenter,
+		"compute_resource": computeResources,
+		"storage_resource": storageResources,
+	}
+}
+
+// placementStatsToList converts the stats of a placement into the stat list
+// of a provider.  Values that cannot be stored in the state (infinities and
+// NaN) are written as 0.
+func placementStatsToList(stats []api.StatApiDTO) []interface{} {
+	statList := make([]interface{}, len(stats))
+	for idx, stat := range stats {
+		statList[idx] = map[string]interface{}{
+			"name":  stat.Name,
+			"units": stat.Units,
+			"value": finiteStatValue(stat.Value),
+		}
+	}
+	return statList
+}
+
+// reservationExpired reports whether the reservation's expiration date is
+// past.  Reservations without a (valid) expiration date never expire.
+func reservationExpired(r *api.ReservationResponse, now time.Time) bool {
+	expireTime, parseErr := time.Parse(time.RFC3339, r.ExpireDateTime)
+	if parseErr != nil {
+		return false
+	}
+	return !expireTime.After(now)
+}
+
+// stringInSlice reports whether the value is in the slice
+func stringInSlice(value string, slice []string) bool {
+	for _, v := range slice {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func convertStringSet(set *schema.Set) []string {
 	s := make([]string, 0, set.Len())
 	for _, v := range set.List() {
@@ -292,13 +1100,13 @@ func convertStringSet(set *schema.Set) []string {
 
 // Poll status of reservation to verify if placement has succeeded for reservation
 // A Successful reservation is when we recieve "PLACEMENT_SUCCEEDED" or "RESERVED" status
-func refreshReservation(d *schema.ResourceData, meta interface{}) resource.StateRefreshFunc {
+func refreshReservation(ctx context.Context, d *schema.ResourceData, meta interface{}) resource.StateRefreshFunc {
 	return func() (interface{}, string, error) {
 		log.Debugf("Refreshing reservation state")
 
 		client := meta.(*api.Client)
 
-		resDetail, err := client.ReadReservation(d.Id())
+		resDetail, err := client.ReadReservation(ctx, d.Id())
 
 		if err != nil {
 			return nil, "Failed", err
@@ -314,12 +1122,13 @@ func refreshReservation(d *schema.ResourceData, meta interface{}) resource.State
 		case "LOADING":
 			return resDetail, resDetail.Status, nil
 		case "UNFULFILLED":
+			// Turbonomic may place unfulfilled reservations once capacity
+			// frees up: Create only accepts them when asked to
+			if !d.Get("wait_for_status").(*schema.Set).Contains(resDetail.Status) {
+				return resDetail, resDetail.Status, fmt.Errorf("reservation unfulfilled, add UNFULFILLED to wait_for_status to accept it")
+			}
 			return resDetail, resDetail.Status, nil
-		case "PLACEMENT_SUCCEEDED":
-			setResourceDataFromReservation(d, resDetail)
-			return resDetail, resDetail.Status, nil
-		case "RESERVED":
-			setResourceDataFromReservation(d, resDetail)
+		case "PLACEMENT_SUCCEEDED", "RESERVED":
 			return resDetail, resDetail.Status, nil
 		case "PLACEMENT_FAILED":
 			return resDetail, resDetail.Status, fmt.Errorf("reservation unfulfilled, environment does not have resources to place the workload")
@@ -0,0 +1,599 @@
+package turbonomic
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api/fake"
+)
+
+func TestResourceTurboReservationCreate(t *testing.T) {
+	client := testCassetteClient(t, "reservation_create")
+
+	d := schema.TestResourceDataRaw(t, resourceTurboReservation().Schema, map[string]interface{}{
+		"action":      "RESERVATION",
+		"entity_name": "tftest.dev.foo.foo.com",
+		"template_id": "T564dbefa-8a99-1aad-542d-a1e751c5beba",
+		"poll_delay":  "0s",
+	})
+
+	if err := resourceTurboReservationCreate(d, client); err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	if d.Id() != "_58zFA7GKEeiLOK9ARx98hA" {
+		t.Fatalf("expected the reservation's UUID as ID, got [%s]", d.Id())
+	}
+	expected := map[string]string{
+		"status":           "RESERVED",
+		"compute_provider": "psc01n06.esx.foo.foo.com",
+		"storage_provider": "lun01_psc01_vmax_foo",
+	}
+	for key, value := range expected {
+		if d.Get(key).(string) != value {
+			t.Errorf("expected %s [%s], got [%s]", key, value, d.Get(key))
+		}
+	}
+	if d.Get("placement.0.compute_provider").(string) != expected["compute_provider"] {
+		t.Errorf("expected the placement of the entity, got [%v]", d.Get("placement"))
+	}
+
+	if err := resourceTurboReservationDelete(d, client); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+}
+
+func TestAccResourceTurboReservation_basic(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.SetReservationPolls(3)
+	profileID := testAccSeedTurboReservation(server)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationConfig(""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusReserved),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "compute_provider", fake.ComputeProvider),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "storage_provider", fake.StorageProvider),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "deployment_profile_id", profileID),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_multiple(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationMultipleConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckTurboReservationDemandEntities(server, 3),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusReserved),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "compute_provider", fake.ComputeProvider),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.#", "3"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.0.entity_name", "web01.fake.local"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.entity_name", "web02.fake.local"),
+					resource.TestCheckResourceAttrSet("turbonomic_reservation.test", "placement.2.entity_id"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.compute_provider", fake.ComputeProvider),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.storage_provider", fake.StorageProvider),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.compute_resource.#", "1"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.compute_resource.0.provider", fake.ComputeProvider),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.compute_resource.0.stat.0.name", "numOfCpu"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.compute_resource.0.stat.0.value", "2"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.storage_resource.#", "2"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.storage_resource.0.disk_type", api.ResourceTypeDisk),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.storage_resource.1.provider", fake.StorageProvider),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.storage_resource.1.stat.0.name", "diskSize"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.storage_resource.1.stat.0.value", "51200"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_demands(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+	dbTemplateID := testAccSeedTurboReservationDB(server)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationDemandsConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckTurboReservationDemandEntities(server, 3),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusReserved),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.#", "3"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.#", "2"),
+					resource.TestCheckResourceAttrPair(
+						"turbonomic_reservation.test", "demand_result.0.template_id",
+						"data.turbonomic_template.test", "id",
+					),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.0.placement.#", "2"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.0.placement.1.entity_name", "web02.fake.local"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.1.template_id", dbTemplateID),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.1.placement.#", "1"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.1.placement.0.entity_name", "db01.fake.local"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.1.placement.0.compute_provider", fake.ComputeProvider),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_demandsPlacementFailed(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+	server.FailPlacement(testAccSeedTurboReservationDB(server))
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				// the web workloads are not reserved without the database
+				Config:      testAccProviderConfig(server) + testAccTurboReservationDemandsConfig,
+				ExpectError: regexp.MustCompile("environment does not have resources"),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_unfulfilled(t *testing.T) {
+	server := testAccFakeServer(t)
+	server.UnfulfillPlacement(testAccSeedTurboReservationDB(server))
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccProviderConfig(server) + testAccTurboReservationUnfulfilledConfig(""),
+				ExpectError: regexp.MustCompile("reservation unfulfilled, add UNFULFILLED to wait_for_status"),
+			},
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationUnfulfilledConfig(`wait_for_status = ["UNFULFILLED"]`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusUnfulfilled),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "compute_provider", ""),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_antiAffinity(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+	server.AddHosts(2, 2)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationAntiAffinityConfig(3, `high_availability = true`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusReserved),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.0.host_count", "3"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.0.datacenter_count", "0"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.0.compute_provider", fake.HostName(0)),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.2.compute_provider", fake.HostName(2)),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.2.datacenter", ""),
+				),
+			},
+			{
+				Config:      testAccProviderConfig(server) + testAccTurboReservationAntiAffinityConfig(2, `priority = "URGENT"`),
+				ExpectError: regexp.MustCompile(`expected priority to be one of \[LOW NORMAL HIGH\]`),
+			},
+			{
+				Config:      testAccProviderConfig(server) + testAccTurboReservationAntiAffinityConfig(2, `geographic_redundancy = true`),
+				ExpectError: regexp.MustCompile(`geographicRedundancy is not supported on this Turbonomic version`),
+			},
+			{
+				Config:      testAccProviderConfig(server) + testAccTurboReservationAntiAffinityConfig(2, `priority = "HIGH"`),
+				ExpectError: regexp.MustCompile(`priority is not supported on this Turbonomic version`),
+			},
+			{
+				PreConfig: func() { server.SetVersion(`Turbonomic Operations Manager 6.4.10 (Build "20210602")`) },
+				Config: testAccProviderConfig(server) + testAccTurboReservationAntiAffinityConfig(2, `
+  geographic_redundancy = true
+  priority              = "HIGH"
+`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusReserved),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.0.host_count", "2"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.0.datacenter_count", "2"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.0.datacenter", "fake-dc-01"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.compute_provider", fake.HostName(2)),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.1.datacenter", "fake-dc-02"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_deployTimeUnsupported(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				// the fake server reports Turbonomic XL
+				Config:      testAccProviderConfig(server) + testAccTurboReservationConfig(`reservation_deploy_time = "2030-01-01T00:00:00Z"`),
+				ExpectError: regexp.MustCompile("deployDateTime is not supported on this Turbonomic version"),
+			},
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationConfig(""),
+				Check:  resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusReserved),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_future(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+	reserveTime := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccProviderConfig(server) + testAccTurboReservationConfig(`wait_for_status = ["PLACEMENT_FAILED"]`),
+				ExpectError: regexp.MustCompile(`expected wait_for_status\.\d+ to be one of`),
+			},
+			{
+				// the reservation is not replaced while it is pending
+				Config: testAccProviderConfig(server) + testAccTurboReservationConfig(fmt.Sprintf(`
+  reservation_reserve_time = "%s"
+  wait_for_status          = ["FUTURE"]
+  required_status          = "RESERVED"
+`, reserveTime)),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusFuture),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "placement.#", "0"),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "compute_provider", ""),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "demand_result.0.placement.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_createTimeout(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+	reserveTime := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if reservations := server.Reservations(); len(reservations) != 0 {
+				return fmt.Errorf("expected the pending reservation to be deleted, got %+v", reservations)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationConfig(fmt.Sprintf(`
+  reservation_reserve_time = "%s"
+
+  timeouts {
+    create = "1s"
+  }
+`, reserveTime)),
+				ExpectError: regexp.MustCompile("timeout while waiting for state"),
+			},
+		},
+	})
+}
+
+func TestAccResourceTurboReservation_drift(t *testing.T) {
+	server := testAccFakeServer(t)
+	testAccSeedTurboReservation(server)
+	var ids []string
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboReservationDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + testAccTurboReservationConfig(`required_status = "RESERVED"`),
+				Check:  testAccCheckTurboReservationReplaced(server, &ids),
+			},
+			{
+				// the reservation expired: it is reserved again
+				PreConfig: func() { server.ExpireReservation(ids[0]) },
+				Config:    testAccProviderConfig(server) + testAccTurboReservationConfig(`required_status = "RESERVED"`),
+				Check:     testAccCheckTurboReservationReplaced(server, &ids),
+			},
+			{
+				// a market change dropped the reservation: it is replaced
+				PreConfig: func() { server.SetReservationStatus(ids[1], fake.StatusUnfulfilled) },
+				Config:    testAccProviderConfig(server) + testAccTurboReservationConfig(`required_status = "RESERVED"`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckTurboReservationReplaced(server, &ids),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusReserved),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "compute_provider", fake.ComputeProvider),
+				),
+			},
+			{
+				// required_status is updated in place
+				Config: testAccProviderConfig(server) + testAccTurboReservationConfig(""),
+				Check:  testAccCheckTurboReservationID(&ids),
+			},
+			{
+				// without required_status, the drop is only refreshed
+				PreConfig: func() { server.SetReservationStatus(ids[2], fake.StatusUnfulfilled) },
+				Config:    testAccProviderConfig(server) + testAccTurboReservationConfig(""),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckTurboReservationID(&ids),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "status", fake.StatusUnfulfilled),
+					resource.TestCheckResourceAttr("turbonomic_reservation.test", "compute_provider", ""),
+				),
+			},
+		},
+	})
+}
+
+// testAccSeedTurboReservation adds the market, policy, deployment profile
+// and template used by testAccTurboReservationConfig to the fake server and
+// returns the deployment profile's UUID
+func testAccSeedTurboReservation(server *fake.Server) string {
+	marketID := server.AddMarket(api.TurboMarket{DisplayName: "Market"})
+	server.AddMarketPolicy(marketID, api.TurboMarketPolicy{
+		DisplayName: "tf_acc_vm_placement",
+		Type:        "BIND_TO_GROUP",
+		Enabled:     true,
+	})
+	profileID := server.AddDeploymentProfile(api.DeploymentProfileApiDTO{
+		DisplayName: "DEP-tf-acc",
+	})
+	server.AddTemplate(api.TemplateApiDTO{
+		ClassName:         "VirtualMachineProfile",
+		DisplayName:       "TMP-tf-acc",
+		DeploymentProfile: api.DeploymentProfileApiDTO{UUID: profileID, DisplayName: "DEP-tf-acc"},
+		Discovered:        true,
+		Model:             "vcenter.fake.local::TMP-tf-acc",
+		ComputeResources: []api.ResourceApiDTO{{
+			Stats: []api.StatApiDTO{{Name: "numOfCpu", Value: 2}},
+		}},
+		StorageResources: []api.ResourceApiDTO{
+			{Type: api.ResourceTypeDisk, Stats: []api.StatApiDTO{{Name: "diskSize", Value: 20480}}},
+			{Type: api.ResourceTypeDisk, Stats: []api.StatApiDTO{{Name: "diskSize", Value: 51200}}},
+		},
+	})
+	return profileID
+}
+
+// testAccSeedTurboReservationDB adds the database template and deployment
+// profile used by testAccTurboReservationDemandsConfig to the fake server and
+// returns the template's UUID
+func testAccSeedTurboReservationDB(server *fake.Server) string {
+	profileID := server.AddDeploymentProfile(api.DeploymentProfileApiDTO{
+		DisplayName: "DEP-tf-acc-db",
+	})
+	return server.AddTemplate(api.TemplateApiDTO{
+		DisplayName:       "TMP-tf-acc-db",
+		DeploymentProfile: api.DeploymentProfileApiDTO{UUID: profileID, DisplayName: "DEP-tf-acc-db"},
+		Discovered:        true,
+		Model:             "vcenter.fake.local::TMP-tf-acc-db",
+	})
+}
+
+// testAccTurboReservationPolling polls the fake server without delay: it
+// places reservations after a set number of reads rather than after a time
+const testAccTurboReservationPolling = `
+  poll_delay    = "0s"
+  poll_interval = "10ms"
+`
+
+func testAccTurboReservationConfig(extra string) string {
+	return fmt.Sprintf(`
+data "turbonomic_template" "test" {
+  display_name           = "TMP-tf-acc"
+  vcenter_server         = "vcenter.fake.local"
+  has_deployment_profile = true
+}
+
+data "turbonomic_market" "test" {}
+
+data "turbonomic_market_policy" "test" {
+  display_name = "tf_acc_vm_placement"
+  market_id    = "${data.turbonomic_market.test.id}"
+}
+
+resource "turbonomic_reservation" "test" {
+  action                = "RESERVATION"
+  entity_name           = "tftest.fake.local"
+  template_id           = "${data.turbonomic_template.test.id}"
+  deployment_profile_id = "${data.turbonomic_template.test.deployment_profile_id}"
+  constraint_ids        = ["${data.turbonomic_market_policy.test.id}"]
+  %s
+  %s
+}
+`, testAccTurboReservationPolling, extra)
+}
+
+const testAccTurboReservationMultipleConfig = `
+data "turbonomic_template" "test" {
+  display_name           = "TMP-tf-acc"
+  vcenter_server         = "vcenter.fake.local"
+  has_deployment_profile = true
+}
+
+resource "turbonomic_reservation" "test" {
+  action                = "RESERVATION"
+  entity_names          = ["web01.fake.local", "web02.fake.local"]
+  instance_count        = 3
+  template_id           = "${data.turbonomic_template.test.id}"
+  deployment_profile_id = "${data.turbonomic_template.test.deployment_profile_id}"
+` + testAccTurboReservationPolling + `}
+`
+
+const testAccTurboReservationDemandsConfig = `
+data "turbonomic_template" "test" {
+  display_name           = "TMP-tf-acc"
+  vcenter_server         = "vcenter.fake.local"
+  has_deployment_profile = true
+}
+
+data "turbonomic_template" "db" {
+  display_name           = "TMP-tf-acc-db"
+  vcenter_server         = "vcenter.fake.local"
+  has_deployment_profile = true
+}
+
+resource "turbonomic_reservation" "test" {
+  action = "RESERVATION"
+` + testAccTurboReservationPolling + `
+  demand {
+    template_id           = "${data.turbonomic_template.test.id}"
+    deployment_profile_id = "${data.turbonomic_template.test.deployment_profile_id}"
+    entity_names          = ["web01.fake.local", "web02.fake.local"]
+  }
+
+  demand {
+    template_id           = "${data.turbonomic_template.db.id}"
+    deployment_profile_id = "${data.turbonomic_template.db.deployment_profile_id}"
+    entity_names          = ["db01.fake.local"]
+  }
+}
+`
+
+func testAccTurboReservationUnfulfilledConfig(extra string) string {
+	return fmt.Sprintf(`
+data "turbonomic_template" "db" {
+  display_name           = "TMP-tf-acc-db"
+  vcenter_server         = "vcenter.fake.local"
+  has_deployment_profile = true
+}
+
+resource "turbonomic_reservation" "test" {
+  action      = "RESERVATION"
+  entity_name = "db01.fake.local"
+  template_id = "${data.turbonomic_template.db.id}"
+  %s
+  %s
+}
+`, testAccTurboReservationPolling, extra)
+}
+
+func testAccTurboReservationAntiAffinityConfig(count int, extra string) string {
+	return fmt.Sprintf(`
+data "turbonomic_template" "test" {
+  display_name           = "TMP-tf-acc"
+  vcenter_server         = "vcenter.fake.local"
+  has_deployment_profile = true
+}
+
+resource "turbonomic_reservation" "test" {
+  action                = "RESERVATION"
+  entity_names          = ["web01.fake.local"]
+  instance_count        = %d
+  template_id           = "${data.turbonomic_template.test.id}"
+  deployment_profile_id = "${data.turbonomic_template.test.deployment_profile_id}"
+  %s
+  %s
+}
+`, count, testAccTurboReservationPolling, extra)
+}
+
+// testAccCheckTurboReservationDemandEntities verifies the reservation in
+// state was created on the fake server with the given number of instances
+func testAccCheckTurboReservationDemandEntities(server *fake.Server, count int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["turbonomic_reservation.test"]
+		if !ok {
+			return fmt.Errorf("turbonomic_reservation.test not found in state")
+		}
+		res, ok := server.Reservation(rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("Reservation [%s] not found", rs.Primary.ID)
+		}
+		if res.Count != count || len(res.DemandEntities) != count {
+			return fmt.Errorf(
+				"Reservation [%s] has count [%d] and [%d] demand entities, expected [%d]",
+				rs.Primary.ID,
+				res.Count,
+				len(res.DemandEntities),
+				count,
+			)
+		}
+		if res.DisplayName != "web01.fake.local" {
+			return fmt.Errorf("Reservation [%s] is named [%s]", rs.Primary.ID, res.DisplayName)
+		}
+		return nil
+	}
+}
+
+// testAccCheckTurboReservationReplaced verifies the reservation in state is
+// a new one, placed on the fake server, and records its UUID
+func testAccCheckTurboReservationReplaced(server *fake.Server, ids *[]string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["turbonomic_reservation.test"]
+		if !ok {
+			return fmt.Errorf("turbonomic_reservation.test not found in state")
+		}
+		for _, id := range *ids {
+			if id == rs.Primary.ID {
+				return fmt.Errorf("Reservation [%s] was not replaced", id)
+			}
+			if _, ok := server.Reservation(id); ok {
+				return fmt.Errorf("Replaced reservation [%s] still exists", id)
+			}
+		}
+		res, ok := server.Reservation(rs.Primary.ID)
+		if !ok || res.Status != fake.StatusReserved {
+			return fmt.Errorf("Reservation [%s] is not reserved: %+v", rs.Primary.ID, res)
+		}
+		*ids = append(*ids, rs.Primary.ID)
+		return nil
+	}
+}
+
+// testAccCheckTurboReservationID verifies the reservation in state is the
+// last one recorded by testAccCheckTurboReservationReplaced
+func testAccCheckTurboReservationID(ids *[]string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		last := (*ids)[len(*ids)-1]
+		return resource.TestCheckResourceAttr("turbonomic_reservation.test", "id", last)(s)
+	}
+}
+
+// testAccCheckTurboReservationDestroy verifies the reservations in state were
+// deleted from the fake server
+func testAccCheckTurboReservationDestroy(server *fake.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "turbonomic_reservation" {
+				continue
+			}
+			if _, ok := server.Reservation(rs.Primary.ID); ok {
+				return fmt.Errorf("Reservation [%s] still exists", rs.Primary.ID)
+			}
+		}
+		return nil
+	}
+}
@@ -3,6 +3,7 @@ package turbonomic
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	autodoc "github.com/foo/terraform-provider-utils/autodoc"
 	log "github.com/foo/terraform-provider-utils/log"
@@ -12,6 +13,10 @@ import (
 	"github.com/hashicorp/terraform/helper/validation"
 )
 
+const (
+	templateOperationTimeout = 5 * time.Minute
+)
+
 func resourceTurboTemplate() *schema.Resource {
 	return &schema.Resource{
 
@@ -20,6 +25,13 @@ func resourceTurboTemplate() *schema.Resource {
 		Update: resourceTurboTemplateUpdate,
 		Delete: resourceTurboTemplateDelete,
 
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(templateOperationTimeout),
+			Read:   schema.DefaultTimeout(templateOperationTimeout),
+			Update: schema.DefaultTimeout(templateOperationTimeout),
+			Delete: schema.DefaultTimeout(templateOperationTimeout),
+		},
+
 		Schema: map[string]*schema.Schema{
 			autodoc.MetaAttribute: &schema.Schema{
 				Type:     schema.TypeBool,
@@ -383,9 +395,11 @@ func mapstructToStatApiDTO(m map[string]interface{}) api.StatApiDTO {
 	if obj.Units, ok = m["units"].(string); !ok {
 		obj.Units = ""
 	}
-	if obj.Value, ok = m["value"].(float64); !ok {
-		obj.Value = 0
+	var value float64
+	if value, ok = m["value"].(float64); !ok {
+		value = 0
 	}
+	obj.Value = api.StatFloat(value)
 
 	log.Debugf("StatApiDTO: [%+v]", obj)
 	return obj
@@ -399,7 +413,7 @@ func statApiDTOToMapstruct(obj api.StatApiDTO) map[string]interface{} {
 	return map[string]interface{}{
 		"name":  obj.Name,
 		"units": obj.Units,
-		"value": obj.Value,
+		"value": float64(obj.Value),
 	}
 }
 
@@ -422,7 +436,10 @@ func resourceTurboTemplateCreate(d *schema.ResourceData, meta interface{}) error
 
 	log.Debugf("TemplateApiInputDTO: [%+v]", inObj)
 
-	createObj, createErr := client.CreateTemplate(inObj)
+	ctx, cancel := operationContext(d, schema.TimeoutCreate)
+	defer cancel()
+
+	createObj, createErr := client.CreateTemplate(ctx, inObj)
 	if createErr != nil {
 		return createErr
 	}
@@ -442,7 +459,16 @@ func resourceTurboTemplateRead(d *schema.ResourceData, meta interface{}) error {
 
 	log.Debugf("TemplateApiDTO: [%+v]", obj)
 
-	readObj, readErr := client.ReadTemplate(obj.UUID)
+	ctx, cancel := operationContext(d, schema.TimeoutRead)
+	defer cancel()
+
+	readObj, readErr := client.ReadTemplate(ctx, obj.UUID)
+	if api.IsNotFound(readErr) {
+		// the template was deleted outside of Terraform
+		log.Infof("Template [%s] no longer exists, removing it from state", obj.UUID)
+		d.SetId("")
+		return nil
+	}
 	if readErr != nil {
 		return readErr
 	}
@@ -469,7 +495,10 @@ func resourceTurboTemplateUpdate(d *schema.ResourceData, meta interface{}) error
 
 	log.Debugf("TemplateApiInputDTO: [%+v]", inObj)
 
-	updateObj, updateErr := client.UpdateTemplate(obj.UUID, inObj)
+	ctx, cancel := operationContext(d, schema.TimeoutUpdate)
+	defer cancel()
+
+	updateObj, updateErr := client.UpdateTemplate(ctx, obj.UUID, inObj)
 	if updateErr != nil {
 		return updateErr
 	}
@@ -489,5 +518,13 @@ func resourceTurboTemplateDelete(d *schema.ResourceData, meta interface{}) error
 
 	log.Debugf("TemplateApiDTO: [%+v]", obj)
 
-	return client.DeleteTemplate(obj.UUID)
+	ctx, cancel := operationContext(d, schema.TimeoutDelete)
+	defer cancel()
+
+	deleteErr := client.DeleteTemplate(ctx, obj.UUID)
+	if api.IsNotFound(deleteErr) {
+		// already deleted outside of Terraform
+		return nil
+	}
+	return deleteErr
 }
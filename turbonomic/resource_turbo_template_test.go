@@ -0,0 +1,158 @@
+package turbonomic
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"github.foo.com/shared/terraform-provider-turbonomic/turbonomic/api/fake"
+)
+
+func TestResourceTurboTemplateCRUD(t *testing.T) {
+	client := testCassetteClient(t, "template_crud")
+
+	d := schema.TestResourceDataRaw(t, resourceTurboTemplate().Schema, map[string]interface{}{
+		"class_name":   "VirtualMachine",
+		"display_name": "tf-cassette-template",
+		"description":  "created by terraform",
+		"compute_resource": []interface{}{
+			map[string]interface{}{"name": "numOfCpu", "value": 2},
+			map[string]interface{}{"name": "cpuSpeed", "units": "MHz", "value": 2400},
+			map[string]interface{}{"name": "memorySize", "units": "MB", "value": 4096},
+		},
+		"storage_resource": []interface{}{
+			map[string]interface{}{"name": "diskSize", "units": "GB", "value": 40},
+		},
+	})
+
+	if err := resourceTurboTemplateCreate(d, client); err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	if d.Id() != "_Tf0dAFCeEemPhZlGvSH2DA" {
+		t.Fatalf("expected the created template's UUID as ID, got [%s]", d.Id())
+	}
+
+	if err := resourceTurboTemplateRead(d, client); err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	if d.Get("class_name").(string) != "VirtualMachineProfile" {
+		t.Errorf("expected class_name [VirtualMachineProfile], got [%s]", d.Get("class_name"))
+	}
+	if d.Get("compute_resource").(*schema.Set).Len() != 3 {
+		t.Errorf("expected 3 compute resources, got %v", d.Get("compute_resource"))
+	}
+
+	d.Set("class_name", "VirtualMachine")
+	d.Set("description", "updated by terraform")
+	if err := resourceTurboTemplateUpdate(d, client); err != nil {
+		t.Fatalf("Update: %s", err)
+	}
+	if d.Get("description").(string) != "updated by terraform" {
+		t.Errorf("expected the updated description, got [%s]", d.Get("description"))
+	}
+
+	if err := resourceTurboTemplateDelete(d, client); err != nil {
+		t.Fatalf("Delete: %s", err)
+	}
+	// the template no longer exists: Read removes it from state
+	if err := resourceTurboTemplateRead(d, client); err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	if d.Id() != "" {
+		t.Fatalf("expected the deleted template to be removed from state")
+	}
+}
+
+func TestAccResourceTurboTemplate_basic(t *testing.T) {
+	server := testAccFakeServer(t)
+
+	resource.UnitTest(t, resource.TestCase{
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckTurboTemplateDestroy(server),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) +
+					testAccTurboTemplateConfig("created by terraform"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckTurboTemplateDescription(server, "created by terraform"),
+					resource.TestCheckResourceAttr("turbonomic_template.test", "class_name", "VirtualMachineProfile"),
+					resource.TestCheckResourceAttr("turbonomic_template.test", "compute_resource.#", "3"),
+					resource.TestCheckResourceAttr("turbonomic_template.test", "storage_resource.#", "1"),
+				),
+			},
+			{
+				Config: testAccProviderConfig(server) +
+					testAccTurboTemplateConfig("updated by terraform"),
+				Check: testAccCheckTurboTemplateDescription(server, "updated by terraform"),
+			},
+		},
+	})
+}
+
+func testAccTurboTemplateConfig(description string) string {
+	return fmt.Sprintf(`
+resource "turbonomic_template" "test" {
+  class_name   = "VirtualMachine"
+  display_name = "tf-acc-template"
+  description  = "%s"
+
+  compute_resource {
+    name  = "numOfCpu"
+    value = 2
+  }
+  compute_resource {
+    name  = "cpuSpeed"
+    units = "MHz"
+    value = 2400
+  }
+  compute_resource {
+    name  = "memorySize"
+    units = "MB"
+    value = 4096
+  }
+
+  storage_resource {
+    name  = "diskSize"
+    units = "GB"
+    value = 40
+  }
+}
+`, description)
+}
+
+// testAccCheckTurboTemplateDescription verifies the description of the
+// template stored by the fake server
+func testAccCheckTurboTemplateDescription(server *fake.Server, description string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources["turbonomic_template.test"]
+		if !ok {
+			return fmt.Errorf("turbonomic_template.test not found in state")
+		}
+		template, ok := server.Template(rs.Primary.ID)
+		if !ok {
+			return fmt.Errorf("Template [%s] not found on the server", rs.Primary.ID)
+		}
+		if template.Description != description {
+			return fmt.Errorf("Expected description [%s], got [%s]", description, template.Description)
+		}
+		return nil
+	}
+}
+
+// testAccCheckTurboTemplateDestroy verifies the templates in state were
+// deleted from the fake server
+func testAccCheckTurboTemplateDestroy(server *fake.Server) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "turbonomic_template" {
+				continue
+			}
+			if _, ok := server.Template(rs.Primary.ID); ok {
+				return fmt.Errorf("Template [%s] still exists", rs.Primary.ID)
+			}
+		}
+		return nil
+	}
+}